@@ -23,9 +23,10 @@ require (
 	github.com/ory/dockertest v3.3.2+incompatible
 	github.com/pkg/errors v0.8.0
 	github.com/satori/go.uuid v1.2.0
+	github.com/segmentio/kafka-go v0.3.5
 	github.com/sirupsen/logrus v1.0.5
 	github.com/streadway/amqp v0.0.0-20180315184602-8e4aba63da9f
-	golang.org/x/crypto v0.0.0-20180621125126-a49355c7e3f8
-	golang.org/x/net v0.0.0-20180629035331-4cb1c02c05b0
-	golang.org/x/sys v0.0.0-20180627142611-7138fd3d9dc8
+	golang.org/x/crypto v0.0.0-20190506204251-e1dfcc566284
+	golang.org/x/net v0.0.0-20190404232315-eb5bcb51f2a3
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d
 )
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+	"github.com/SmartEnergyPlatform/util/http/response"
+)
+
+func admin(router *jwt_http_router.Router, db interfaces.Persistence) {
+
+	router.GET("/admin/outbox", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		state := r.URL.Query().Get("state")
+		if state != "" && state != eventsourcing.OutboxPending && state != eventsourcing.OutboxFailed {
+			response.To(res).DefaultError("unknown state: "+state, http.StatusBadRequest)
+			return
+		}
+		entries, err := eventsourcing.ListOutbox(state)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.To(res).Json(entries)
+	})
+
+	router.POST("/admin/outbox/:id/retry", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		err := eventsourcing.RetryOutboxEntry(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	router.DELETE("/admin/outbox/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		err := eventsourcing.DiscardOutboxEntry(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	router.GET("/admin/deadletters", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		entries, err := eventsourcing.ListDeadLetters(r.URL.Query().Get("topic"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.To(res).Json(entries)
+	})
+
+	router.GET("/admin/deadletters/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		entry, err := eventsourcing.GetDeadLetter(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusNotFound)
+			return
+		}
+		response.To(res).Json(entry)
+	})
+
+	router.POST("/admin/deadletters/:id/replay", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		err := eventsourcing.ReplayDeadLetter(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	router.DELETE("/admin/deadletters/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		err := eventsourcing.DiscardDeadLetter(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	router.POST("/admin/flush", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		request := model.FlushRequest{}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := db.StartFlush(request)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Json(job)
+	})
+
+	router.GET("/admin/flush", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		response.To(res).Json(db.ListFlushJobs())
+	})
+
+	router.GET("/admin/flush/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		job, err := db.GetFlushJob(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusNotFound)
+			return
+		}
+		response.To(res).Json(job)
+	})
+
+	router.DELETE("/admin/flush/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		err := db.CancelFlushJob(ps.ByName("id"))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Text("ok")
+	})
+}
@@ -36,14 +36,15 @@ import (
 //creates all rest-endpoints, initiates the request-logger
 func Init(db interfaces.Persistence) {
 	if util.Config.FlushOnStartup == "true" {
-		log.Println("flush database to event")
-		db.FlushDevicetypes()
-		db.FlushDevices()
-		db.FlushGateways()
-		db.FlushValueTypes()
+		log.Println("flush database to event in background")
+		//one job keeps referenced entities ahead of the entities referencing them
+		_, err := db.StartFlush(model.FlushRequest{Kinds: []string{model.KindValueType, model.KindDeviceType, model.KindDeviceInstance, model.KindGateway}, RateLimit: float64(util.Config.FlushRateLimit)})
+		if err != nil {
+			log.Println("ERROR: unable to start flush", err)
+		}
 	}
 	log.Println("start server on port: ", util.Config.ServerPort)
-	httpHandler := GetRoutes(db)
+	httpHandler := NewGatewayAuthMiddleWare(GetRoutes(db), GetGatewayRoutes(db))
 	corsHandler := cors.New(httpHandler)
 	logger := logger.New(corsHandler, util.Config.LogLevel)
 	if util.Config.DecodeUrlFix == "true" {
@@ -66,6 +67,9 @@ func GetRoutes(db interfaces.Persistence) *jwt_http_router.Router {
 	other(router, db)
 	gateway(router, db)
 	intern(router, db)
+	admin(router, db)
+	command(router, db)
+	permissions(router, db)
 
 	return router
 }
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+	"github.com/SmartEnergyPlatform/util/http/response"
+)
+
+const CorrelationIdHeader = "X-Correlation-Id"
+const CommandStateHeader = "X-Command-State"
+
+func command(router *jwt_http_router.Router, db interfaces.Persistence) {
+
+	router.GET("/commands/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		wait, err := getCommandWait(r)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, ok := eventsourcing.GetCommandStatus(id)
+		if !ok {
+			response.To(res).DefaultError("unknown command", http.StatusNotFound)
+			return
+		}
+		//commands without owner (e.g. deletes published by other instances) are only visible to admins
+		if status.Owner != jwt.UserId && !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("access denied", http.StatusUnauthorized)
+			return
+		}
+		if wait > 0 {
+			status, ok = eventsourcing.WaitForCommand(id, wait)
+			if !ok {
+				response.To(res).DefaultError("unknown command", http.StatusNotFound)
+				return
+			}
+		}
+		response.To(res).Json(status)
+	})
+}
+
+//reads the optional query parameter wait (seconds); limited by util.Config.CommandMaxWait
+func getCommandWait(r *http.Request) (wait time.Duration, err error) {
+	waitStr := r.URL.Query().Get("wait")
+	if waitStr == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseInt(waitStr, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if seconds > util.Config.CommandMaxWait {
+		seconds = util.Config.CommandMaxWait
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+//sets the correlation id header of the published commands and handles the optional query parameter wait
+//waits until a consumer applied the commands; returns false if an error response is written
+func commandResponse(res http.ResponseWriter, r *http.Request, jwt jwt_http_router.Jwt, correlationIds ...string) bool {
+	ids := []string{}
+	for _, id := range correlationIds {
+		if id != "" {
+			eventsourcing.SetCommandOwner(id, jwt.UserId)
+			ids = append(ids, id)
+		}
+	}
+	res.Header().Set(CorrelationIdHeader, strings.Join(ids, ","))
+	wait, err := getCommandWait(r)
+	if err != nil {
+		response.To(res).DefaultError("commands are published but "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+	deadline := time.Now().Add(wait)
+	state := eventsourcing.CommandApplied
+	errors := []string{}
+	for _, id := range ids {
+		status, ok := eventsourcing.WaitForCommand(id, deadline.Sub(time.Now()))
+		switch {
+		case !ok || status.State == eventsourcing.CommandPending:
+			if state == eventsourcing.CommandApplied {
+				state = eventsourcing.CommandPending
+			}
+		case status.State == eventsourcing.CommandFailed:
+			state = eventsourcing.CommandFailed
+			errors = append(errors, status.Error)
+		}
+	}
+	res.Header().Set(CommandStateHeader, state)
+	if state == eventsourcing.CommandFailed {
+		response.To(res).Error(response.ErrorMessage{StatusCode: http.StatusInternalServerError, Message: "command failed", ErrorCode: response.ERROR_GENERIC, Detail: errors})
+		return false
+	}
+	return true
+}
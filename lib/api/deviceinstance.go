@@ -137,11 +137,14 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			deviceInstance.ImgUrl = dt.ImgUrl
 		}
 
-		err = eventsourcing.PublishDeviceInstance(deviceInstance, jwt.UserId)
+		correlationId, err := eventsourcing.PublishDeviceInstance(deviceInstance, jwt.UserId)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(deviceInstance)
 	})
 
@@ -177,12 +180,15 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 
-		err = eventsourcing.PublishDeviceInstance(deviceInstance, "")
+		correlationId, err := eventsourcing.PublishDeviceInstance(deviceInstance, "")
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(deviceInstance)
 	})
 
@@ -197,11 +203,14 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = eventsourcing.PublishDeviceInstanceRemove(id)
+		correlationId, err := eventsourcing.PublishDeviceInstanceRemove(id)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(deviceInstance)
 	})
 
@@ -330,18 +339,22 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
-		filteredEndpoints := []model.Endpoint{}
-		for _, endpoint := range endpoints {
-			service, err := db.GetServiceById(endpoint.Service)
-			if err != nil {
-				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if err := permission.Check(jwt, util.Config.DeviceInstanceTopic, endpoint.Device, model.EXECUTE); err == nil && service.ServiceType == "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Sensor" {
-				filteredEndpoints = append(filteredEndpoints, endpoint)
-			}
+		response.To(res).Json(permittedSensorEndpoints(jwt, endpoints))
+	})
+
+	router.POST("/endpoint/in/batch", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		var request model.EndpointResolveRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		endpoints, err := db.GetEndpointsByList(request.Endpoints, request.ProtocolHandler)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
 		}
-		response.To(res).Json(filteredEndpoints)
+		response.To(res).Json(permittedSensorEndpoints(jwt, endpoints))
 	})
 
 	router.POST("/endpoint/listen/auth/check/:handler/:endpoint", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
@@ -352,9 +365,18 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		deviceIds := []string{}
 		for _, endpoint := range endpoints {
-			if err := permission.Check(jwt, util.Config.DeviceInstanceTopic, endpoint.Device, model.EXECUTE); err != nil {
-				response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			deviceIds = append(deviceIds, endpoint.Device)
+		}
+		allowed, err := permission.CheckMultiple(jwt, util.Config.DeviceInstanceTopic, deviceIds, model.EXECUTE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		for _, id := range deviceIds {
+			if !allowed[id] {
+				response.To(res).DefaultError("access denied", http.StatusUnauthorized)
 				return
 			}
 		}
@@ -372,7 +394,7 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
 			return
 		}
-		endpoint, err := db.GetEndpointByDeviceAndService(e.Device, e.Service)
+		endpoint, err := db.GetEndpointByDeviceAndService(e.Device, e.Service, e.ProtocolHandler, e.Role)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
@@ -387,11 +409,14 @@ func deviceinstance(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		endpoint, err := gen.CreateNewEndpoint(db, e, jwt.UserId)
+		endpoint, correlationId, err := gen.CreateNewEndpoint(db, e, jwt.UserId)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json([]model.Endpoint{endpoint})
 	})
 
@@ -444,3 +469,25 @@ func bydevicetype(devicetype string, db interfaces.Persistence, jwt jwt_http_rou
 	}
 	return
 }
+
+//endpoints of sensor services of devices with execute permission; checked with one permission request
+func permittedSensorEndpoints(jwt jwt_http_router.Jwt, endpoints []model.Endpoint) (result []model.Endpoint) {
+	deviceIds := []string{}
+	for _, endpoint := range endpoints {
+		if endpoint.ServiceType == model.SensorServiceType {
+			deviceIds = append(deviceIds, endpoint.Device)
+		}
+	}
+	result = []model.Endpoint{}
+	if len(deviceIds) == 0 {
+		return
+	}
+	//ids are denied if the check fails
+	allowed, _ := permission.CheckMultiple(jwt, util.Config.DeviceInstanceTopic, deviceIds, model.EXECUTE)
+	for _, endpoint := range endpoints {
+		if endpoint.ServiceType == model.SensorServiceType && allowed[endpoint.Device] {
+			result = append(result, endpoint)
+		}
+	}
+	return
+}
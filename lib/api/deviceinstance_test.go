@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+//allows execute for the listed devices and records the checked ids
+type engineMock struct {
+	permission.Engine
+	allowed map[string]bool
+	checked [][]string
+	err     error
+}
+
+func (this *engineMock) CheckMultiple(jwt jwt_http_router.Jwt, kind string, ids []string, right string) (allowed map[string]bool, err error) {
+	this.checked = append(this.checked, ids)
+	allowed = map[string]bool{}
+	for _, id := range ids {
+		allowed[id] = this.allowed[id] && right == "x" && kind == "deviceinstance"
+	}
+	return allowed, this.err
+}
+
+func Example_permittedSensorEndpoints() {
+	util.Config = &util.ConfigStruct{DeviceInstanceTopic: "deviceinstance"}
+	engine := &engineMock{allowed: map[string]bool{"d1": true, "d3": true}}
+	permission.SetEngine(engine)
+	defer permission.SetEngine(nil)
+
+	endpoints := []model.Endpoint{
+		{Endpoint: "e1", Device: "d1", ServiceType: model.SensorServiceType},
+		{Endpoint: "e2", Device: "d2", ServiceType: model.SensorServiceType},
+		{Endpoint: "e3", Device: "d3", ServiceType: "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Actuator"},
+		{Endpoint: "e4", Device: "d1", ServiceType: model.SensorServiceType},
+	}
+	print := func(result []model.Endpoint) {
+		names := []string{}
+		for _, endpoint := range result {
+			names = append(names, endpoint.Endpoint)
+		}
+		fmt.Println(names)
+	}
+
+	//one check for the devices of all sensor endpoints
+	print(permittedSensorEndpoints(jwt_http_router.Jwt{UserId: "user"}, endpoints))
+	fmt.Println(engine.checked)
+
+	//no check without sensor endpoints
+	engine.checked = nil
+	print(permittedSensorEndpoints(jwt_http_router.Jwt{UserId: "user"}, endpoints[2:3]))
+	fmt.Println(len(engine.checked))
+
+	//failed checks deny all endpoints
+	engine.err = errors.New("permissionsearch unavailable")
+	engine.allowed = map[string]bool{}
+	print(permittedSensorEndpoints(jwt_http_router.Jwt{UserId: "user"}, endpoints))
+
+	//Output:
+	//[e1 e4]
+	//[[d1 d2 d1]]
+	//[]
+	//0
+	//[]
+}
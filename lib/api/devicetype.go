@@ -151,6 +151,11 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 	})
 
 	router.GET("/deviceType/:id/:depth", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		//the router does not allow /deviceType/:id/permissions beside this route
+		if ps.ByName("depth") == "permissions" {
+			getPermissions(util.Config.DeviceTypeTopic)(res, r, ps, jwt)
+			return
+		}
 		depth, err := strconv.Atoi(ps.ByName("depth"))
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
@@ -193,11 +198,14 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = eventsourcing.PublishDeviceType(deviceType, jwt.UserId)
+		correlationId, err := eventsourcing.PublishDeviceType(deviceType, jwt.UserId)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(deviceType)
 	})
 
@@ -216,11 +224,14 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError("missing id", http.StatusBadRequest)
 			return
 		}
-		err = eventsourcing.PublishDeviceType(deviceType, jwt.UserId)
+		correlationId, err := eventsourcing.PublishDeviceType(deviceType, jwt.UserId)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(deviceType)
 	})
 
@@ -263,15 +274,131 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 
-		err = eventsourcing.PublishDeviceType(deviceType, "")
+		correlationId, err := eventsourcing.PublishDeviceType(deviceType, "")
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Json(deviceType)
+	})
+
+	router.POST("/deviceType/:id/review/accept", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		err := permission.Check(jwt, util.Config.DeviceTypeTopic, id, model.WRITE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var review model.DeviceTypeReview
+		err = json.NewDecoder(r.Body).Decode(&review)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		deviceType, changedValueTypes, err := db.GetAcceptedDeviceType(id, review)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, valueTypeId := range changedValueTypes {
+			err = permission.Check(jwt, util.Config.ValueTypeTopic, valueTypeId, model.WRITE)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		valid, validErr := deviceType.IsValid()
+		if !valid {
+			response.To(res).DefaultError("invalid deviceType: "+validErr, http.StatusBadRequest)
+			return
+		}
+		ok, inconsistencies := db.DeviceTypeIsConsistent(deviceType)
+		if !ok {
+			response.To(res).Error(response.ErrorMessage{StatusCode: http.StatusBadRequest, Message: "inconsistencies found", ErrorCode: response.ERROR_INCONSISTENT_NEW_ELEMENT, Detail: []string{inconsistencies}})
+			return
+		}
+		correlationIds := []string{}
+		for valueTypeId, name := range review.ValueTypeNames {
+			correlationId, err := eventsourcing.PublishValueTypeRename(valueTypeId, name)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			correlationIds = append(correlationIds, correlationId)
+		}
+		correlationId, err := eventsourcing.PublishReviewedDeviceType(deviceType)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, append(correlationIds, correlationId)...) {
+			return
+		}
 		response.To(res).Json(deviceType)
 	})
 
+	router.POST("/deviceType/:id/review/reject", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		err := permission.Check(jwt, util.Config.DeviceTypeTopic, id, model.ADMINISTRATE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		err = db.CheckDeviceTypeReject(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		deviceInstancesIds, err := db.GetAllDeviceInstanceUsingDeviceTypes(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		dependent := []string{}
+		for _, instanceId := range deviceInstancesIds {
+			instance, err := db.GetDeviceInstanceById(instanceId)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !contains(instance.Tags, model.GeneratedDeviceInstanceTag) {
+				dependent = append(dependent, instanceId)
+				continue
+			}
+			err = permission.Check(jwt, util.Config.DeviceInstanceTopic, instanceId, model.ADMINISTRATE)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		if len(dependent) > 0 {
+			response.To(res).Error(response.ErrorMessage{StatusCode: http.StatusBadRequest, Message: "dependent device instances", ErrorCode: response.ERROR_DEPENDENT_DEVICE_INSTANCE, Detail: dependent})
+			return
+		}
+		correlationIds := []string{}
+		for _, instanceId := range deviceInstancesIds {
+			correlationId, err := eventsourcing.PublishDeviceInstanceRemove(instanceId)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			correlationIds = append(correlationIds, correlationId)
+		}
+		correlationId, err := eventsourcing.PublishDeviceTypeRemove(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, append(correlationIds, correlationId)...) {
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
 	router.DELETE("/deviceType/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
 		id := ps.ByName("id")
 		err := permission.Check(jwt, util.Config.DeviceTypeTopic, id, model.ADMINISTRATE)
@@ -285,11 +412,14 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 
-		err = eventsourcing.PublishDeviceTypeRemove(id)
+		correlationId, err := eventsourcing.PublishDeviceTypeRemove(id)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
 	})
 
@@ -331,10 +461,11 @@ func devicetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 
+		//ids are denied if the check fails
+		allowed, _ := permission.CheckMultiple(jwt, util.Config.DeviceTypeTopic, ids, model.READ)
 		result := []string{}
 		for _, id := range ids {
-			err = permission.Check(jwt, util.Config.DeviceTypeTopic, id, model.READ)
-			if err == nil {
+			if allowed[id] {
 				result = append(result, id)
 			}
 		}
@@ -22,6 +22,9 @@ import (
 	"net/http"
 
 	"encoding/json"
+	"errors"
+	"io"
+	"time"
 
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 
@@ -73,6 +76,35 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 		response.To(res).Json(gateway)
 	})
 
+	router.GET("/gateway/:id/tree", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.READ)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		tree, err := db.GetGatewayTree(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tree.Name == "" {
+			response.To(res).DefaultError("unknown gateway", http.StatusNotFound)
+			return
+		}
+		ids := gatewayTreeIds(tree.Gateways)
+		allowed := map[string]bool{}
+		if len(ids) > 0 {
+			allowed, err = permission.CheckMultiple(jwt, util.Config.GatewayTopic, ids, model.READ)
+			if err != nil {
+				//ids are denied if the check fails
+				log.Println("WARNING: unable to check gateway tree permissions", err)
+				allowed = map[string]bool{}
+			}
+		}
+		response.To(res).Json(filterGatewayTree(tree, allowed))
+	})
+
 	router.DELETE("/gateway/:id", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
 		id := ps.ByName("id")
 		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.ADMINISTRATE)
@@ -80,11 +112,14 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
 			return
 		}
-		err = eventsourcing.PublisGatewayRemove(id)
+		correlationId, err := eventsourcing.PublisGatewayRemove(id)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
 	})
 
@@ -115,12 +150,16 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 		gw.Devices = []model.DeviceInstance{}
+		gw.Gateways = []string{}
 		gw.Hash = ""
-		err = eventsourcing.PublishGateway(gw, "")
+		correlationId, err := eventsourcing.PublishGateway(gw, "")
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
 	})
 
@@ -138,11 +177,14 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 		gw.Name = name
-		err = eventsourcing.PublishGateway(gw, "")
+		correlationId, err := eventsourcing.PublishGateway(gw, "")
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
 	})
 
@@ -161,26 +203,187 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 		response.To(res).Text(name)
 	})
 
-	//ignores model.GatewayRef.Id and ueses :id
-	router.POST("/gateway/:id/commit", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+	router.POST("/gateway/:id/commit", gatewayCommit(db, authorizeGatewayUser(db)))
+
+	//the body {"version": "..."} is optional
+	router.POST("/gateway/:id/heartbeat", gatewayHeartbeat(db, authorizeGatewayUser(db)))
+
+	router.POST("/gateway/:id/sync", gatewaySync(db, authorizeGatewayUser(db)))
+
+	router.GET("/gateway/:id/state", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
 		id := ps.ByName("id")
-		ready, gw, err := gatewayIsReady(jwt, db, id)
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.READ)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		connection, err := db.GetGatewayConnection(id)
 		if err != nil {
-			log.Println("DEBUG: gatewayIsReady() error:", err)
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if !ready {
-			log.Println("DEBUG: gateway is not ready")
-			response.To(res).DefaultError("gateway not ready", http.StatusPreconditionFailed)
+		response.To(res).Json(connection)
+	})
+
+	router.GET("/gateway/:id/provide", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		if id != "" {
+			gw, err := db.GetGateway(id)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if gw.Name != "" {
+				allowed, err := permission.CheckBool(jwt, util.Config.GatewayTopic, id, model.EXECUTE)
+				if err != nil {
+					response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if !allowed {
+					id = ""
+				}
+			}
+		}
+		gateway, isNew, err := db.ProvideGateway(id, jwt.UserId)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = permission.Check(jwt, util.Config.GatewayTopic, id, model.WRITE)
+		correlationId := ""
+		if isNew {
+			correlationId, err = eventsourcing.PublishGateway(gateway, jwt.UserId)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Json(gateway)
+	})
+
+	//creates a one-time token to claim a credential for the gateway (see /gateways/claim); a new token replaces older tokens of the gateway
+	router.POST("/gateway/:id/claim", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.ADMINISTRATE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		name, err := db.GetGatewayName(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if name == "" {
+			response.To(res).DefaultError("unknown gateway", http.StatusNotFound)
+			return
+		}
+		secret, err := newGatewaySecret()
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		expires := time.Now().UTC().Add(time.Duration(util.Config.GatewayClaimTokenTTL) * time.Second).Format(time.RFC3339)
+		token, err := db.ProvideGatewayClaimToken(model.GatewayClaimToken{Gateway: id, Hash: hashGatewaySecret(secret), Creator: jwt.UserId, Expires: expires})
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		correlationId, err := eventsourcing.PublishGatewayClaimToken(token)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Json(model.GatewayClaimTokenResponse{Id: token.Id, Gateway: id, Token: secret, Expires: expires})
+	})
+
+	router.GET("/gateway/:id/credentials", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.ADMINISTRATE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		credentials, err := db.GetGatewayCredentials(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for index := range credentials {
+			credentials[index].Hash = ""
+		}
+		response.To(res).Json(credentials)
+	})
+
+	router.DELETE("/gateway/:id/credentials/:credential", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		credentialId := ps.ByName("credential")
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.ADMINISTRATE)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		credential, err := db.GetGatewayCredential(credentialId)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if credential.Gateway != id {
+			response.To(res).DefaultError("unknown credential", http.StatusNotFound)
+			return
+		}
+		correlationId, err := eventsourcing.PublishGatewayRevoke(id, []string{credentialId})
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	//replaces the key credential with a new key
+	router.POST("/gateway/:id/credentials/:credential/rotate", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		credentialId := ps.ByName("credential")
+		err := permission.Check(jwt, util.Config.GatewayTopic, id, model.ADMINISTRATE)
 		if err != nil {
-			log.Println("DEBUG: permission check error:", err)
 			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
 			return
 		}
+		credential, err := db.GetGatewayCredential(credentialId)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if credential.Gateway != id {
+			response.To(res).DefaultError("unknown credential", http.StatusNotFound)
+			return
+		}
+		if credential.Kind != model.GatewayCredentialKey {
+			response.To(res).DefaultError("only key credentials can be rotated", http.StatusBadRequest)
+			return
+		}
+		rotateGatewayKey(res, r, jwt, db, credential)
+	})
+}
+
+//ignores model.GatewayRef.Id and ueses :id
+func gatewayCommit(db interfaces.Persistence, authorize gatewayAuthorization) jwt_http_router.Handle {
+	return func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		gw, asGateway, status, err := authorize(r, jwt, id)
+		if err != nil {
+			log.Println("DEBUG: gateway authorization error:", err)
+			response.To(res).DefaultError(err.Error(), status)
+			return
+		}
 		var gateway model.GatewayRef
 		err = json.NewDecoder(r.Body).Decode(&gateway)
 		if err != nil {
@@ -188,6 +391,11 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
+		err = checkConnectedGateways(jwt, gw, gateway.Gateways, asGateway)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
 		err = db.GatewayCheckCommit(id, gateway)
 		if err != nil {
 			log.Println("DEBUG: GatewayCheckCommit() error:", err)
@@ -195,49 +403,103 @@ func gateway(router *jwt_http_router.Router, db interfaces.Persistence) {
 			return
 		}
 		gateway.Id = id
-		err = eventsourcing.PublishGatewayRef(gateway, gw.Name, "")
+		correlationId, err := eventsourcing.PublishGatewayRef(gateway, gw.Name, "")
 		if err != nil {
 			log.Println("DEBUG: eventsourcing.PublishGatewayRef() error:", err)
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
 		log.Println("DEBUG: gateway commited", err)
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
-	})
+	}
+}
 
-	router.GET("/gateway/:id/provide", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
-		id := ps.ByName("id")
-		if id != "" {
-			gw, err := db.GetGateway(id)
-			if err != nil {
-				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if gw.Name != "" {
-				allowed, err := permission.CheckBool(jwt, util.Config.GatewayTopic, id, model.EXECUTE)
-				if err != nil {
-					response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
-					return
-				}
-				if !allowed {
-					id = ""
-				}
+//users need write access to connected gateways; gateways authenticated by their credentials may not connect additional gateways
+func checkConnectedGateways(jwt jwt_http_router.Jwt, gw model.Gateway, gateways []string, asGateway bool) error {
+	if len(gateways) == 0 {
+		return nil
+	}
+	if asGateway {
+		for _, child := range gateways {
+			if !contains(gw.Gateways, child) {
+				return errors.New("gateway " + child + " has to be connected by a user")
 			}
 		}
-		gateway, isNew, err := db.ProvideGateway(id, jwt.UserId)
+		return nil
+	}
+	allowed, err := permission.CheckMultiple(jwt, util.Config.GatewayTopic, gateways, model.WRITE)
+	if err != nil {
+		return err
+	}
+	for _, child := range gateways {
+		if !allowed[child] {
+			return errors.New("access denied for gateway " + child)
+		}
+	}
+	return nil
+}
+
+func gatewayHeartbeat(db interfaces.Persistence, authorize gatewayAuthorization) jwt_http_router.Handle {
+	return func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		_, _, status, err := authorize(r, jwt, id)
 		if err != nil {
+			response.To(res).DefaultError(err.Error(), status)
+			return
+		}
+		heartbeat := model.GatewayConnection{}
+		err = json.NewDecoder(r.Body).Decode(&heartbeat)
+		if err != nil && err != io.EOF {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		if isNew {
-			err = eventsourcing.PublishGateway(gateway, jwt.UserId)
+		correlationId, err := eventsourcing.RecordGatewayHeartbeat(db, id, heartbeat.Version)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Text("ok")
+	}
+}
+
+func gatewaySync(db interfaces.Persistence, authorize gatewayAuthorization) jwt_http_router.Handle {
+	return func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		_, _, status, err := authorize(r, jwt, id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), status)
+			return
+		}
+		request := model.GatewaySyncRequest{}
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && err != io.EOF {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, current, stored, err := db.GetGatewaySync(id, request.Hash)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		correlationId := ""
+		if current.Hash != stored.Hash {
+			correlationId, err = eventsourcing.PublishGatewaySync(current)
 			if err != nil {
 				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
-		response.To(res).Json(gateway)
-	})
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Json(result)
+	}
 }
 
 func gatewayIsReady(jwt jwt_http_router.Jwt, db interfaces.Persistence, id string) (exists bool, gw model.Gateway, err error) {
@@ -250,3 +512,23 @@ func gatewayIsReady(jwt jwt_http_router.Jwt, db interfaces.Persistence, id strin
 	exists, err = permission.Exists(jwt, util.Config.GatewayTopic, id)
 	return
 }
+
+func gatewayTreeIds(gateways []model.GatewayTree) (ids []string) {
+	for _, gateway := range gateways {
+		ids = append(ids, gateway.Id)
+		ids = append(ids, gatewayTreeIds(gateway.Gateways)...)
+	}
+	return
+}
+
+//removes connected gateways (and their subtrees) the user may not read
+func filterGatewayTree(tree model.GatewayTree, allowed map[string]bool) model.GatewayTree {
+	gateways := []model.GatewayTree{}
+	for _, gateway := range tree.Gateways {
+		if allowed[gateway.Id] {
+			gateways = append(gateways, filterGatewayTree(gateway, allowed))
+		}
+	}
+	tree.Gateways = gateways
+	return tree
+}
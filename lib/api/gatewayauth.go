@@ -0,0 +1,259 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+	"github.com/SmartEnergyPlatform/util/http/response"
+)
+
+const GatewayKeyHeader = "X-Gateway-Key"
+
+//returns the gateway if the request may act for it; asGateway is true if the gateway is authenticated by one of its credentials
+type gatewayAuthorization func(r *http.Request, jwt jwt_http_router.Jwt, id string) (gw model.Gateway, asGateway bool, status int, err error)
+
+//routes of gateways authenticated by their key or by a claim token; user jwts are not required
+func GetGatewayRoutes(db interfaces.Persistence) *jwt_http_router.Router {
+	router := jwt_http_router.New(jwt_http_router.JwtConfig{PubRsa: util.Config.JwtPubRsa})
+
+	//redeems a claim token; key credentials are returned once, jwt credentials register the subject of the request jwt
+	router.POST("/gateways/claim", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		request := model.GatewayClaimRequest{}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Kind == "" {
+			request.Kind = model.GatewayCredentialKey
+		}
+		if request.Kind == model.GatewayCredentialJwt && jwt.UserId == "" {
+			response.To(res).DefaultError("jwt credentials need the jwt of the gateway", http.StatusBadRequest)
+			return
+		}
+		if request.Kind != model.GatewayCredentialKey && request.Kind != model.GatewayCredentialJwt {
+			response.To(res).DefaultError("unknown credential kind", http.StatusBadRequest)
+			return
+		}
+		token, err := db.GetGatewayClaimTokenByHash(hashGatewaySecret(request.Token))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if token.Id == "" || !eventsourcing.ClaimTokenIsValid(token, time.Now()) {
+			response.To(res).DefaultError("invalid claim token", http.StatusUnauthorized)
+			return
+		}
+		key := ""
+		credential := model.GatewayCredential{Gateway: token.Gateway, Kind: request.Kind, Created: time.Now().UTC().Format(time.RFC3339)}
+		if request.Kind == model.GatewayCredentialKey {
+			key, err = newGatewaySecret()
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			credential.Hash = hashGatewaySecret(key)
+		} else {
+			current, err := db.GetGatewayCredentialBySubject(jwt.UserId)
+			if err != nil {
+				response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if current.Id != "" && current.Gateway != token.Gateway {
+				response.To(res).DefaultError("subject is already registered for a other gateway", http.StatusConflict)
+				return
+			}
+			credential.Subject = jwt.UserId
+		}
+		credential, err = db.ProvideGatewayCredential(credential)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		correlationId, err := eventsourcing.PublishGatewayCredential(credential, &token, nil)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		//the claim may still be rejected by the consumer (e.g. concurrent redemption of the token), so the result is always awaited
+		res.Header().Set(CorrelationIdHeader, correlationId)
+		status, _ := eventsourcing.WaitForCommand(correlationId, time.Duration(util.Config.CommandMaxWait)*time.Second)
+		res.Header().Set(CommandStateHeader, status.State)
+		result := model.GatewayCredentialResponse{Id: credential.Id, Gateway: credential.Gateway, Kind: credential.Kind, Key: key, Subject: credential.Subject}
+		switch status.State {
+		case eventsourcing.CommandApplied:
+			response.To(res).Json(result)
+		case eventsourcing.CommandFailed:
+			response.To(res).DefaultError("claim failed: "+status.Error, http.StatusConflict)
+		default:
+			//the key becomes valid when the command is applied
+			res.Header().Set("Content-Type", "application/json")
+			res.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(res).Encode(result)
+		}
+	})
+
+	router.POST("/gateway/:id/commit", gatewayCommit(db, authorizeGatewayKey(db)))
+
+	router.POST("/gateway/:id/heartbeat", gatewayHeartbeat(db, authorizeGatewayKey(db)))
+
+	router.POST("/gateway/:id/sync", gatewaySync(db, authorizeGatewayKey(db)))
+
+	//replaces the key of the request with a new key
+	router.POST("/gateway/:id/credentials/rotate", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		credential, err := db.GetGatewayCredentialByHash(hashGatewaySecret(r.Header.Get(GatewayKeyHeader)))
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if credential.Id == "" || credential.Gateway != id {
+			response.To(res).DefaultError("invalid gateway key", http.StatusUnauthorized)
+			return
+		}
+		rotateGatewayKey(res, r, jwt, db, credential)
+	})
+
+	return router
+}
+
+//requests with a gateway key and requests unknown to the user routes (e.g. /gateways/claim) are handled by the gateway routes
+type GatewayAuthMiddleWare struct {
+	users    *jwt_http_router.Router
+	gateways *jwt_http_router.Router
+}
+
+func NewGatewayAuthMiddleWare(users *jwt_http_router.Router, gateways *jwt_http_router.Router) *GatewayAuthMiddleWare {
+	return &GatewayAuthMiddleWare{users: users, gateways: gateways}
+}
+
+func (this *GatewayAuthMiddleWare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(GatewayKeyHeader) != "" {
+		this.gateways.ServeHTTP(w, r)
+		return
+	}
+	if handle, _, _ := this.users.Lookup(r.Method, r.URL.Path); handle == nil {
+		if handle, _, _ := this.gateways.Lookup(r.Method, r.URL.Path); handle != nil {
+			this.gateways.ServeHTTP(w, r)
+			return
+		}
+	}
+	this.users.ServeHTTP(w, r)
+}
+
+//jwts with a subject registered as credential of the gateway act as the gateway; other users need write access
+//users are rejected if util.Config.GatewayCredentialsRequired is "true"
+func authorizeGatewayUser(db interfaces.Persistence) gatewayAuthorization {
+	return func(r *http.Request, jwt jwt_http_router.Jwt, id string) (gw model.Gateway, asGateway bool, status int, err error) {
+		if jwt.UserId != "" {
+			credential, err := db.GetGatewayCredentialBySubject(jwt.UserId)
+			if err != nil {
+				return gw, false, http.StatusInternalServerError, err
+			}
+			if credential.Gateway == id {
+				return authorizedGateway(db, id)
+			}
+		}
+		if util.Config.GatewayCredentialsRequired == "true" {
+			return gw, false, http.StatusUnauthorized, errors.New("gateway credentials required")
+		}
+		ready, gw, err := gatewayIsReady(jwt, db, id)
+		if err != nil {
+			return gw, false, http.StatusInternalServerError, err
+		}
+		if !ready {
+			return gw, false, http.StatusPreconditionFailed, errors.New("gateway not ready")
+		}
+		err = permission.Check(jwt, util.Config.GatewayTopic, id, model.WRITE)
+		if err != nil {
+			return gw, false, http.StatusUnauthorized, err
+		}
+		return gw, false, http.StatusOK, nil
+	}
+}
+
+//the key of the GatewayKeyHeader has to be a credential of the gateway
+func authorizeGatewayKey(db interfaces.Persistence) gatewayAuthorization {
+	return func(r *http.Request, jwt jwt_http_router.Jwt, id string) (gw model.Gateway, asGateway bool, status int, err error) {
+		credential, err := db.GetGatewayCredentialByHash(hashGatewaySecret(r.Header.Get(GatewayKeyHeader)))
+		if err != nil {
+			return gw, false, http.StatusInternalServerError, err
+		}
+		if credential.Id == "" || credential.Gateway != id {
+			return gw, false, http.StatusUnauthorized, errors.New("invalid gateway key")
+		}
+		return authorizedGateway(db, id)
+	}
+}
+
+func authorizedGateway(db interfaces.Persistence, id string) (gw model.Gateway, asGateway bool, status int, err error) {
+	gw, err = db.GetGateway(id)
+	if err != nil {
+		return gw, false, http.StatusInternalServerError, err
+	}
+	if gw.Name == "" {
+		return gw, false, http.StatusPreconditionFailed, errors.New("gateway not ready")
+	}
+	return gw, true, http.StatusOK, nil
+}
+
+//publishes a new key credential which revokes the old one; the new key is returned once
+func rotateGatewayKey(res http.ResponseWriter, r *http.Request, jwt jwt_http_router.Jwt, db interfaces.Persistence, old model.GatewayCredential) {
+	key, err := newGatewaySecret()
+	if err != nil {
+		response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	credential, err := db.ProvideGatewayCredential(model.GatewayCredential{Gateway: old.Gateway, Kind: model.GatewayCredentialKey, Hash: hashGatewaySecret(key), Created: time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	correlationId, err := eventsourcing.PublishGatewayCredential(credential, nil, []string{old.Id})
+	if err != nil {
+		response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !commandResponse(res, r, jwt, correlationId) {
+		return
+	}
+	response.To(res).Json(model.GatewayCredentialResponse{Id: credential.Id, Gateway: credential.Gateway, Kind: credential.Kind, Key: key})
+}
+
+func newGatewaySecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	return hex.EncodeToString(secret), err
+}
+
+func hashGatewaySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
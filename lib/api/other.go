@@ -140,11 +140,14 @@ func other(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = eventsourcing.PublishValueType(element, jwt.UserId)
+		correlationId, err := eventsourcing.PublishValueType(element, jwt.UserId)
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Json(Insert_OK{CreatedId: element.Id})
 	})
 
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+	"github.com/SmartEnergyPlatform/util/http/response"
+)
+
+func permissions(router *jwt_http_router.Router, db interfaces.Persistence) {
+	router.GET("/deviceInstance/:id/permissions", getPermissions(util.Config.DeviceInstanceTopic))
+	router.PUT("/deviceInstance/:id/permissions", setPermissions(util.Config.DeviceInstanceTopic))
+
+	//GET /deviceType/:id/permissions is handled by GET /deviceType/:id/:depth
+	router.PUT("/deviceType/:id/permissions", setPermissions(util.Config.DeviceTypeTopic))
+
+	router.GET("/gateway/:id/permissions", getPermissions(util.Config.GatewayTopic))
+	router.PUT("/gateway/:id/permissions", setPermissions(util.Config.GatewayTopic))
+}
+
+func getPermissions(kind string) jwt_http_router.Handle {
+	return func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		if err := permission.Check(jwt, kind, id, model.ADMINISTRATE); err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		auth, err := permission.GetAuth(jwt, kind, id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.To(res).Json(auth)
+	}
+}
+
+//replaces the permissions of the resource; the owner can not be changed
+func setPermissions(kind string) jwt_http_router.Handle {
+	return func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		var request model.Auth
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		requested, err := permission.NormalizePermissions(request.Permissions)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := permission.Check(jwt, kind, id, model.ADMINISTRATE); err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		auth, err := permission.GetAuth(jwt, kind, id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if request.Owner != "" && request.Owner != auth.Owner {
+			response.To(res).DefaultError("owner can not be changed", http.StatusBadRequest)
+			return
+		}
+		correlationId, err := eventsourcing.PublishPermissions(kind, id, auth.Permissions, requested)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		auth.Permissions = requested
+		response.To(res).Json(auth)
+	}
+}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -69,21 +70,57 @@ func valuetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 		response.To(res).Json(valueType)
 	})
 
+	router.GET("/valueType/:id/usages", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		id := ps.ByName("id")
+		valueType, err := db.GetValueTypeById(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if valueType.Name == "" {
+			response.To(res).DefaultError("unknown value type", http.StatusNotFound)
+			return
+		}
+		usages, err := db.GetValueTypeUsages(id)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.To(res).Json(usages)
+	})
+
 	router.POST("/valueType/generate", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(r.Body)
 		msg := buf.String()
-		vt, format, _, err := gen.ValueTypeFromMessage(db, msg)
+		vt, format, _, conflicts, err := gen.ValueTypeFromMessages(db, []string{msg}, r.URL.Query().Get("name"))
 		if err != nil {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		response.To(res).Json(struct {
-			ValueType model.ValueType `json:"value_type"`
-			Format    gen.Format      `json:"format"`
-		}{
+		response.To(res).Json(GeneratedValueType{
 			ValueType: vt,
 			Format:    format,
+			Conflicts: conflicts,
+		})
+	})
+
+	router.POST("/valueType/generate/samples", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		samples := ValueTypeSamples{}
+		err := json.NewDecoder(r.Body).Decode(&samples)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		vt, format, _, conflicts, err := gen.ValueTypeFromMessages(db, samples.Samples, samples.Name)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.To(res).Json(GeneratedValueType{
+			ValueType: vt,
+			Format:    format,
+			Conflicts: conflicts,
 		})
 	})
 
@@ -99,12 +136,69 @@ func valuetype(router *jwt_http_router.Router, db interfaces.Persistence) {
 			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = eventsourcing.PublishValueTypeRemove(id)
+		correlationId, err := eventsourcing.PublishValueTypeRemove(id)
+		if err != nil {
+			log.Println("ERROR:", err)
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
+		response.To(res).Text("ok")
+	})
+
+	router.GET("/admin/valueTypes/duplicates", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		clusters, err := db.FindEquivalentValueTypes()
+		if err != nil {
+			log.Println("ERROR: ", err)
+			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.To(res).Json(clusters)
+	})
+
+	router.POST("/admin/valueTypes/merge", func(res http.ResponseWriter, r *http.Request, ps jwt_http_router.Params, jwt jwt_http_router.Jwt) {
+		if !contains(jwt.RealmAccess.Roles, "admin") {
+			response.To(res).DefaultError("only for admins", http.StatusUnauthorized)
+			return
+		}
+		var merge model.ValueTypeMerge
+		err := json.NewDecoder(r.Body).Decode(&merge)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = db.CheckValueTypeMerge(merge.Canonical, merge.Duplicates)
+		if err != nil {
+			response.To(res).DefaultError(err.Error(), http.StatusBadRequest)
+			return
+		}
+		correlationId, err := eventsourcing.PublishValueTypeMerge(merge.Canonical, merge.Duplicates)
 		if err != nil {
 			log.Println("ERROR:", err)
 			response.To(res).DefaultError(err.Error(), http.StatusInternalServerError)
 			return
 		}
+		//the consumer of the merge publishes the deletion of the duplicates after all references are replaced
+		if !commandResponse(res, r, jwt, correlationId) {
+			return
+		}
 		response.To(res).Text("ok")
 	})
 }
+
+type ValueTypeSamples struct {
+	Name    string   `json:"name"`
+	Samples []string `json:"samples"`
+}
+
+type GeneratedValueType struct {
+	ValueType model.ValueType `json:"value_type"`
+	Format    gen.Format      `json:"format"`
+	Conflicts []string        `json:"conflicts,omitempty"`
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"errors"
+
+	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+)
+
+//uses a fanout exchange per topic and a durable queue per consumer name
+type Amqp struct {
+	url              string
+	reconnectTimeout int64
+	conn             *amqp_wrapper_lib.Connection
+}
+
+//reconnectTimeout in seconds
+func NewAmqp(url string, reconnectTimeout int64) *Amqp {
+	return &Amqp{url: url, reconnectTimeout: reconnectTimeout}
+}
+
+func (this *Amqp) InitTopics(topics []string) (err error) {
+	this.conn, err = amqp_wrapper_lib.Init(this.url, topics, this.reconnectTimeout)
+	return
+}
+
+func (this *Amqp) Publish(topic string, payload []byte) error {
+	if this.conn == nil {
+		return errors.New("amqp connection not initialized")
+	}
+	return this.conn.Publish(topic, payload)
+}
+
+func (this *Amqp) Consume(consumerName string, topic string, handler ConsumerFunc) error {
+	if this.conn == nil {
+		return errors.New("amqp connection not initialized")
+	}
+	return this.conn.Consume(consumerName, topic, amqp_wrapper_lib.ConsumerFunc(handler))
+}
+
+func (this *Amqp) Close() {
+	if this.conn != nil {
+		this.conn.Close()
+	}
+}
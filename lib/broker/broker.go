@@ -0,0 +1,30 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+//handles one consumed message; an error causes a redelivery of the message
+type ConsumerFunc func(msg []byte) error
+
+//message broker used to publish and consume commands
+type Broker interface {
+	//declares the topics; has to be called once before Publish() and Consume()
+	InitTopics(topics []string) error
+	Publish(topic string, payload []byte) error
+	//every consumer name receives all messages of the topic in order
+	Consume(consumerName string, topic string, handler ConsumerFunc) error
+	Close()
+}
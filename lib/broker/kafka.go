@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+//uses one partition per topic to keep the order of commands and a consumer group per consumer name
+type Kafka struct {
+	brokers []string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mux     sync.Mutex
+	writers map[string]*kafka.Writer
+	readers []*kafka.Reader
+}
+
+func NewKafka(brokers []string) *Kafka {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Kafka{brokers: brokers, ctx: ctx, cancel: cancel, writers: map[string]*kafka.Writer{}}
+}
+
+//creates missing topics through the controller of the cluster
+func (this *Kafka) InitTopics(topics []string) (err error) {
+	if len(this.brokers) == 0 {
+		return errors.New("missing kafka brokers")
+	}
+	conn, err := kafka.Dial("tcp", this.brokers[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	controller, err := conn.Controller()
+	if err != nil {
+		return err
+	}
+	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return err
+	}
+	defer controllerConn.Close()
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, topic := range topics {
+		log.Println("init kafka topic ", topic)
+		err = controllerConn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1})
+		if err != nil && err != kafka.TopicAlreadyExists {
+			return err
+		}
+		if _, ok := this.writers[topic]; !ok {
+			this.writers[topic] = kafka.NewWriter(kafka.WriterConfig{Brokers: this.brokers, Topic: topic, BatchSize: 1})
+		}
+	}
+	return nil
+}
+
+func (this *Kafka) Publish(topic string, payload []byte) error {
+	this.mux.Lock()
+	writer, ok := this.writers[topic]
+	this.mux.Unlock()
+	if !ok {
+		return errors.New("unknown topic: " + topic)
+	}
+	return writer.WriteMessages(this.ctx, kafka.Message{Value: payload})
+}
+
+func (this *Kafka) Consume(consumerName string, topic string, handler ConsumerFunc) error {
+	log.Println("init consumer for ", topic)
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: this.brokers, GroupID: consumerName, Topic: topic})
+	this.mux.Lock()
+	this.readers = append(this.readers, reader)
+	this.mux.Unlock()
+	go func() {
+		for {
+			msg, err := reader.FetchMessage(this.ctx)
+			if err != nil {
+				if this.ctx.Err() == nil {
+					log.Println("ERROR: unable to fetch kafka message", topic, err)
+				}
+				return
+			}
+			//the offset is committed after the message is handled
+			for err = handler(msg.Value); err != nil; err = handler(msg.Value) {
+				log.Println("error while processing msg; message consumtion will not be committed", err)
+				select {
+				case <-this.ctx.Done():
+					return
+				case <-time.After(3 * time.Second):
+				}
+			}
+			err = reader.CommitMessages(this.ctx, msg)
+			if err != nil {
+				log.Println("ERROR while committing kafka msg", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *Kafka) Close() {
+	this.cancel()
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, reader := range this.readers {
+		reader.Close()
+	}
+	for _, writer := range this.writers {
+		writer.Close()
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+//in process broker; messages published before the first Consume() of a consumer name are not delivered to it
+type Memory struct {
+	RedeliveryDelay time.Duration
+
+	mux    sync.Mutex
+	topics map[string]map[string]*memoryQueue //consumer queues by topic and consumer name
+	closed bool
+}
+
+type memoryQueue struct {
+	mux      sync.Mutex
+	cond     *sync.Cond
+	messages [][]byte
+	closed   bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{RedeliveryDelay: time.Second, topics: map[string]map[string]*memoryQueue{}}
+}
+
+func (this *Memory) InitTopics(topics []string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, topic := range topics {
+		if _, ok := this.topics[topic]; !ok {
+			this.topics[topic] = map[string]*memoryQueue{}
+		}
+	}
+	return nil
+}
+
+func (this *Memory) Publish(topic string, payload []byte) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.closed {
+		return errors.New("broker closed")
+	}
+	queues, ok := this.topics[topic]
+	if !ok {
+		return errors.New("unknown topic: " + topic)
+	}
+	for _, queue := range queues {
+		queue.push(payload)
+	}
+	return nil
+}
+
+//one consumer per consumer name and topic
+func (this *Memory) Consume(consumerName string, topic string, handler ConsumerFunc) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.closed {
+		return errors.New("broker closed")
+	}
+	queues, ok := this.topics[topic]
+	if !ok {
+		return errors.New("unknown topic: " + topic)
+	}
+	if _, ok := queues[consumerName]; ok {
+		return errors.New("consumer already exists: " + consumerName)
+	}
+	queue := &memoryQueue{}
+	queue.cond = sync.NewCond(&queue.mux)
+	queues[consumerName] = queue
+	go func() {
+		for {
+			msg, ok := queue.next()
+			if !ok {
+				return
+			}
+			for err := handler(msg); err != nil; err = handler(msg) {
+				log.Println("error while processing msg; message will be redelivered", err)
+				time.Sleep(this.RedeliveryDelay)
+			}
+			queue.remove()
+		}
+	}()
+	return nil
+}
+
+func (this *Memory) Close() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.closed = true
+	for _, queues := range this.topics {
+		for _, queue := range queues {
+			queue.close()
+		}
+	}
+}
+
+func (this *memoryQueue) push(msg []byte) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.messages = append(this.messages, msg)
+	this.cond.Signal()
+}
+
+//blocks until a message is available; the message stays in the queue until remove() is called
+func (this *memoryQueue) next() (msg []byte, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for len(this.messages) == 0 && !this.closed {
+		this.cond.Wait()
+	}
+	if this.closed {
+		return nil, false
+	}
+	return this.messages[0], true
+}
+
+func (this *memoryQueue) remove() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if len(this.messages) > 0 {
+		this.messages = this.messages[1:]
+	}
+}
+
+func (this *memoryQueue) close() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.closed = true
+	this.cond.Broadcast()
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+func ExampleMemory() {
+	broker := NewMemory()
+	broker.RedeliveryDelay = 0
+	err := broker.InitTopics([]string{"devicetype"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(broker.Publish("unknown", []byte("a")))
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	mux := sync.Mutex{}
+	received := map[string][]string{}
+	failed := false
+	consumer := func(name string) ConsumerFunc {
+		return func(msg []byte) error {
+			mux.Lock()
+			defer mux.Unlock()
+			if name == "search" && !failed {
+				failed = true
+				return errors.New("search unavailable")
+			}
+			received[name] = append(received[name], string(msg))
+			wg.Done()
+			return nil
+		}
+	}
+	fmt.Println(broker.Consume("repository", "devicetype", consumer("repository")))
+	fmt.Println(broker.Consume("search", "devicetype", consumer("search")))
+	fmt.Println(broker.Consume("search", "devicetype", consumer("search")))
+
+	fmt.Println(broker.Publish("devicetype", []byte("a")))
+	fmt.Println(broker.Publish("devicetype", []byte("b")))
+	wg.Wait()
+	broker.Close()
+	fmt.Println(received["repository"], received["search"])
+	fmt.Println(broker.Publish("devicetype", []byte("c")))
+
+	//Output:
+	//unknown topic: unknown
+	//<nil>
+	//<nil>
+	//consumer already exists: search
+	//<nil>
+	//<nil>
+	//[a b] [a b]
+	//broker closed
+}
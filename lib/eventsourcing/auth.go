@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//maintains the auth entries of the embedded permission engine from the consumed commands, like the permissionsearch service does with its rights:
+//the owner of the first PUT command owns the resource, DELETE commands remove the auth entry
+//PUT commands without owner (e.g. of a flush) create missing entries for DefaultPermissionsUser
+func updateAuth(db interfaces.Persistence, kind string, commandType string, id string, owner string) (err error) {
+	if util.Config.PermissionsEngine != "embedded" {
+		return nil
+	}
+	switch commandType {
+	case CommandPut:
+		if owner == "" {
+			owner = util.Config.DefaultPermissionsUser
+		}
+		if owner == "" || id == "" {
+			return nil
+		}
+		auth, err := db.GetAuth(id)
+		if err != nil || auth.Owner != "" {
+			return err
+		}
+		return db.SetAuth(model.Auth{ResourceId: id, Kind: kind, Owner: owner})
+	case CommandDelete:
+		return db.DeleteAuth(id)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//one line of the command log
+type CommandLogEntry struct {
+	Topic   string          `json:"topic"`
+	Time    time.Time       `json:"time"`
+	Message json.RawMessage `json:"message"`
+}
+
+//append only file of applied commands (one json entry per line) in the order of consumption
+type CommandLog struct {
+	mux  sync.Mutex
+	file *os.File
+}
+
+func OpenCommandLog(filename string) (*CommandLog, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandLog{file: file}, nil
+}
+
+func (this *CommandLog) Append(topic string, msg []byte) (err error) {
+	line, err := json.Marshal(CommandLogEntry{Topic: topic, Time: time.Now(), Message: msg})
+	if err != nil {
+		return err
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	_, err = this.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+func (this *CommandLog) Close() error {
+	return this.file.Close()
+}
+
+//appends successfully handled commands to the log; a failed append fails the command to let it be redelivered
+func (this *CommandLog) Consumer(topic string, handler broker.ConsumerFunc) broker.ConsumerFunc {
+	return func(msg []byte) (err error) {
+		err = handler(msg)
+		if err != nil || isTransientCommand(msg) {
+			return err
+		}
+		err = this.Append(topic, msg)
+		if err != nil {
+			log.Println("ERROR: unable to append command to log", topic, err)
+		}
+		return err
+	}
+}
+
+//heartbeats and connection states describe the current connection of a gateway; they are not logged to be replayed
+func isTransientCommand(msg []byte) bool {
+	command := struct {
+		Type string `json:"type"`
+	}{}
+	if json.Unmarshal(msg, &command) != nil {
+		return false
+	}
+	return command.Type == CommandHeartbeat || command.Type == CommandState
+}
+
+//reads all entries of the log; a incomplete last line (e.g. after a crash while writing) is ignored
+func ReadCommandLog(filename string, handler func(entry CommandLogEntry) error) (err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Println("WARNING: ignore incomplete last line of command log")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := CommandLogEntry{}
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			return err
+		}
+		err = handler(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//applies all commands of the log through the command handlers without publishing new commands
+//progress is called after each command with the number of applied and total commands
+func RebuildFromCommandLog(db interfaces.Persistence, filename string, progress func(done int, total int)) (err error) {
+	total := 0
+	err = ReadCommandLog(filename, func(entry CommandLogEntry) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	handlers := map[string]broker.ConsumerFunc{
+		util.Config.DeviceInstanceTopic: getDeviceInstanceCommandHandler(db),
+		util.Config.DeviceTypeTopic:     getDeviceTypeCommandHandler(db),
+		util.Config.GatewayTopic:        getGatewayCommandHandler(db),
+		util.Config.ValueTypeTopic:      getValueTypeCommandHandler(db),
+	}
+	if util.Config.PermissionsTopic != "" {
+		handlers[util.Config.PermissionsTopic] = getPermissionCommandHandler(db)
+	}
+	publishingSuppressed = true
+	defer func() {
+		publishingSuppressed = false
+	}()
+	done := 0
+	return ReadCommandLog(filename, func(entry CommandLogEntry) error {
+		handler, ok := handlers[entry.Topic]
+		if !ok {
+			return errors.New("unknown topic in command log: " + entry.Topic)
+		}
+		err := handler(entry.Message)
+		if err != nil {
+			return errors.New("unable to apply command " + string(entry.Message) + ": " + err.Error())
+		}
+		done++
+		if progress != nil {
+			progress(done, total)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+func Example_commandLog() {
+	dir, err := ioutil.TempDir("", "commandlog")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	util.Config = &util.ConfigStruct{DeviceInstanceTopic: "deviceinstance", DeviceTypeTopic: "devicetype", GatewayTopic: "gateway", ValueTypeTopic: "valuetype"}
+	filename := filepath.Join(dir, "commands.log")
+
+	commandLog, err := OpenCommandLog(filename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	db := &dbMock{instances: map[string]model.DeviceInstance{}}
+	consumer := commandLog.Consumer("deviceinstance", getDeviceInstanceCommandHandler(db))
+	for _, payload := range []DeviceInstancePayload{
+		{Id: "d1", DeviceInstance: model.DeviceInstance{Id: "d1", Name: "lamp"}},
+		{Id: "d2", DeviceInstance: model.DeviceInstance{Id: "d2"}}, //fails and is not logged
+		{Id: "d3", DeviceInstance: model.DeviceInstance{Id: "d3", Name: "plug"}},
+	} {
+		envelope, _ := NewCommandEnvelope(CommandPut, "", payload)
+		msg, _ := json.Marshal(envelope)
+		fmt.Println(consumer(msg))
+	}
+	envelope, _ := NewCommandEnvelope(CommandDelete, "", DeviceInstancePayload{Id: "d1"})
+	msg, _ := json.Marshal(envelope)
+	fmt.Println(consumer(msg))
+
+	//heartbeats are not logged
+	gatewayConsumer := commandLog.Consumer("gateway", getGatewayCommandHandler(&gatewayDbMock{connections: map[string]model.GatewayConnection{"gw1": {Id: "gw1", Online: true}}}))
+	envelope, _ = NewCommandEnvelope(CommandHeartbeat, "", GatewayPayload{Id: "gw1", Connection: &model.GatewayConnection{Id: "gw1", Online: true, LastSeen: "2018-01-01T10:00:00Z"}})
+	msg, _ = json.Marshal(envelope)
+	fmt.Println(gatewayConsumer(msg))
+	commandLog.Close()
+
+	//incomplete line of a crash
+	file, _ := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"topic": "deviceinstance", "mess`)
+	file.Close()
+
+	rebuilt := &dbMock{instances: map[string]model.DeviceInstance{}}
+	err = RebuildFromCommandLog(rebuilt, filename, func(done int, total int) {
+		fmt.Println("progress", done, total)
+	})
+	fmt.Println(err, len(rebuilt.instances), rebuilt.instances["d3"].Name)
+
+	//Output:
+	//<nil>
+	//missing name
+	//<nil>
+	//<nil>
+	//<nil>
+	//progress 1 3
+	//progress 2 3
+	//progress 3 3
+	//<nil> 1 plug
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//result of a command consumed by one instance; published to CommandStatusTopic for the other instances
+type CommandResult struct {
+	CorrelationId string `json:"correlation_id"`
+	Owner         string `json:"owner,omitempty"`
+	Instance      string `json:"instance"`
+	Error         string `json:"error,omitempty"`
+}
+
+//records the result of a command of the shared consumers and publishes it to the other instances
+func recordSharedCommandResult(msg []byte, err error) {
+	correlationId, owner, ok := recordCommandResult(msg, err)
+	if !ok || util.Config.CommandStatusTopic == "" {
+		return
+	}
+	publishErr := publishCommandResult(correlationId, owner, err)
+	if publishErr != nil {
+		log.Println("WARNING: unable to publish command result", correlationId, publishErr)
+	}
+}
+
+//published without outbox; a lost result leaves the command pending on the other instances
+func publishCommandResult(correlationId string, owner string, err error) error {
+	if publishingSuppressed {
+		return nil
+	}
+	if Broker == nil {
+		return errors.New("broker not initialized")
+	}
+	result := CommandResult{CorrelationId: correlationId, Owner: owner, Instance: util.Config.InstanceId}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	msg, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return Broker.Publish(util.Config.CommandStatusTopic, msg)
+}
+
+//records results of other instances; results of this instance are already recorded
+func handleCommandResult(msg []byte) error {
+	result := CommandResult{}
+	err := json.Unmarshal(msg, &result)
+	if err != nil || result.CorrelationId == "" {
+		log.Println("WARNING: ignore invalid command result", string(msg), err)
+		return nil
+	}
+	if result.Instance == util.Config.InstanceId {
+		return nil
+	}
+	if result.Owner != "" {
+		commands.SetOwner(result.CorrelationId, result.Owner)
+	}
+	if result.Error != "" {
+		commands.Record(result.CorrelationId, errors.New(result.Error))
+	} else {
+		commands.Record(result.CorrelationId, nil)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+func Example_handleCommandResult() {
+	util.Config = &util.ConfigStruct{InstanceId: "a", CommandStatusTopic: "commandstatus"}
+	commands = NewCommandStatusRegistry(time.Minute)
+	commands.Register("c1", 2)
+	handle := func(result CommandResult) {
+		msg, _ := json.Marshal(result)
+		fmt.Println(handleCommandResult(msg))
+	}
+
+	//results of this instance are recorded by its consumers
+	handle(CommandResult{CorrelationId: "c1", Instance: "a"})
+	status, _ := commands.Get("c1")
+	fmt.Println(status.State, status.Applied)
+
+	handle(CommandResult{CorrelationId: "c1", Instance: "b"})
+	handle(CommandResult{CorrelationId: "c1", Instance: "c"})
+	status, _ = commands.Get("c1")
+	fmt.Println(status.State, status.Applied)
+
+	handle(CommandResult{CorrelationId: "c2", Owner: "user", Instance: "b", Error: "invalid command"})
+	status, _ = commands.Get("c2")
+	fmt.Println(status.State, status.Error, status.Owner)
+
+	fmt.Println(handleCommandResult([]byte("{}")))
+
+	//Output:
+	//<nil>
+	//pending 0
+	//<nil>
+	//<nil>
+	//applied 2
+	//<nil>
+	//failed invalid command user
+	//<nil>
+}
+
+func Example_publishCommandResult() {
+	util.Config = &util.ConfigStruct{InstanceId: "a", CommandStatusTopic: "commandstatus"}
+	memory := broker.NewMemory()
+	memory.InitTopics([]string{"commandstatus"})
+	received := make(chan string, 1)
+	memory.Consume("test", "commandstatus", func(msg []byte) error {
+		received <- string(msg)
+		return nil
+	})
+	Broker = memory
+	defer func() {
+		memory.Close()
+		Broker = nil
+	}()
+
+	fmt.Println(publishCommandResult("c1", "user", nil))
+	fmt.Println(<-received)
+
+	//Output:
+	//<nil>
+	//{"correlation_id":"c1","owner":"user","instance":"a"}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
+)
+
+//consumed command which could not be applied
+type DeadLetter struct {
+	Id          string    `json:"id"`
+	Sequence    int64     `json:"sequence"`
+	Topic       string    `json:"topic"`
+	Payload     string    `json:"payload"`
+	Error       string    `json:"error"`
+	Attempts    int64     `json:"attempts"`
+	Created     time.Time `json:"created"`
+	LastAttempt time.Time `json:"last_attempt"`
+}
+
+//durable local store of failed commands with one directory per topic
+type DeadLetterQueue struct {
+	dir           string
+	maxAttempts   int64
+	retryInterval time.Duration
+
+	mux      sync.Mutex
+	entries  []DeadLetter //sorted by sequence
+	sequence int64
+	handlers map[string]broker.ConsumerFunc
+	inFlight map[string]bool //replayed dead letters
+
+	//called with the final result of consumed and replayed commands: nil if applied, the cause if moved to (or kept in) the dead letters
+	OnResult func(msg []byte, err error)
+}
+
+func NewDeadLetterQueue(dir string, maxAttempts int64, retryInterval time.Duration) (queue *DeadLetterQueue, err error) {
+	queue = &DeadLetterQueue{
+		dir:           dir,
+		maxAttempts:   maxAttempts,
+		retryInterval: retryInterval,
+		handlers:      map[string]broker.ConsumerFunc{},
+		inFlight:      map[string]bool{},
+	}
+	if dir == "" {
+		return queue, errors.New("missing dead letter queue directory")
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return queue, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return queue, err
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return queue, err
+		}
+		entry := DeadLetter{}
+		err = json.Unmarshal(content, &entry)
+		if err != nil {
+			log.Println("ERROR: unable to read dead letter", file, err)
+			continue
+		}
+		queue.entries = append(queue.entries, entry)
+		if entry.Sequence > queue.sequence {
+			queue.sequence = entry.Sequence
+		}
+	}
+	sort.Slice(queue.entries, func(i, j int) bool {
+		return queue.entries[i].Sequence < queue.entries[j].Sequence
+	})
+	if len(queue.entries) > 0 {
+		log.Println("WARNING: dead letters found:", len(queue.entries))
+	}
+	return queue, nil
+}
+
+func (this *DeadLetterQueue) filename(entry DeadLetter) string {
+	return filepath.Join(this.dir, entry.Topic, fmt.Sprintf("%020d.json", entry.Sequence))
+}
+
+func (this *DeadLetterQueue) index(id string) int {
+	for index, entry := range this.entries {
+		if entry.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+//retries the handler up to maxAttempts times (invalid commands are not retried); the failed command is stored as dead letter and acknowledged
+//if the dead letter can not be stored, the error is returned to let the broker redeliver the command
+func (this *DeadLetterQueue) Consumer(topic string, handler broker.ConsumerFunc) broker.ConsumerFunc {
+	this.mux.Lock()
+	this.handlers[topic] = handler
+	this.mux.Unlock()
+	return func(msg []byte) (err error) {
+		attempts := int64(0)
+		for {
+			err = handler(msg)
+			attempts++
+			if err == nil {
+				this.result(msg, nil)
+				return nil
+			}
+			if IsInvalidCommand(err) || (this.maxAttempts > 0 && attempts >= this.maxAttempts) {
+				break
+			}
+			log.Println("WARNING: unable to handle command; retry", topic, err)
+			time.Sleep(this.retryInterval)
+		}
+		storeErr := this.add(topic, msg, err, attempts)
+		if storeErr != nil {
+			log.Println("ERROR: unable to store dead letter", topic, storeErr)
+			return err
+		}
+		log.Println("ERROR: command moved to dead letters", topic, err)
+		this.result(msg, err)
+		return nil
+	}
+}
+
+func (this *DeadLetterQueue) result(msg []byte, err error) {
+	if this.OnResult != nil {
+		this.OnResult(msg, err)
+	}
+}
+
+func (this *DeadLetterQueue) add(topic string, msg []byte, cause error, attempts int64) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	sequence := this.sequence + 1
+	now := time.Now()
+	entry := DeadLetter{
+		Id:          strconv.FormatInt(sequence, 10),
+		Sequence:    sequence,
+		Topic:       topic,
+		Payload:     string(msg),
+		Error:       cause.Error(),
+		Attempts:    attempts,
+		Created:     now,
+		LastAttempt: now,
+	}
+	err = os.MkdirAll(filepath.Join(this.dir, topic), 0755)
+	if err != nil {
+		return err
+	}
+	err = storeJson(this.filename(entry), entry)
+	if err != nil {
+		return err
+	}
+	this.sequence = sequence
+	this.entries = append(this.entries, entry)
+	return nil
+}
+
+//topic == "" lists all dead letters
+func (this *DeadLetterQueue) List(topic string) (result []DeadLetter) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []DeadLetter{}
+	for _, entry := range this.entries {
+		if topic == "" || entry.Topic == topic {
+			result = append(result, entry)
+		}
+	}
+	return
+}
+
+func (this *DeadLetterQueue) Get(id string) (entry DeadLetter, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	index := this.index(id)
+	if index == -1 {
+		return entry, errors.New("unknown dead letter: " + id)
+	}
+	return this.entries[index], nil
+}
+
+//applies the command again with the handler of its topic; removes the dead letter on success
+func (this *DeadLetterQueue) Replay(id string) (err error) {
+	this.mux.Lock()
+	index := this.index(id)
+	if index == -1 {
+		this.mux.Unlock()
+		return errors.New("unknown dead letter: " + id)
+	}
+	if this.inFlight[id] {
+		this.mux.Unlock()
+		return errors.New("dead letter is currently replayed: " + id)
+	}
+	entry := this.entries[index]
+	handler, ok := this.handlers[entry.Topic]
+	if !ok {
+		this.mux.Unlock()
+		return errors.New("no consumer for topic: " + entry.Topic)
+	}
+	this.inFlight[id] = true
+	this.mux.Unlock()
+
+	handlerErr := handler([]byte(entry.Payload))
+	this.result([]byte(entry.Payload), handlerErr)
+
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.inFlight, id)
+	index = this.index(id)
+	if handlerErr == nil {
+		err = os.Remove(this.filename(entry))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		this.entries = append(this.entries[:index], this.entries[index+1:]...)
+		return nil
+	}
+	entry = this.entries[index]
+	entry.Attempts++
+	entry.Error = handlerErr.Error()
+	entry.LastAttempt = time.Now()
+	err = storeJson(this.filename(entry), entry)
+	if err != nil {
+		log.Println("ERROR: unable to update dead letter", id, err)
+	}
+	this.entries[index] = entry
+	return handlerErr
+}
+
+func (this *DeadLetterQueue) Discard(id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	index := this.index(id)
+	if index == -1 {
+		return errors.New("unknown dead letter: " + id)
+	}
+	if this.inFlight[id] {
+		return errors.New("dead letter is currently replayed: " + id)
+	}
+	err = os.Remove(this.filename(this.entries[index]))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	this.entries = append(this.entries[:index], this.entries[index+1:]...)
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func Example_deadLetters() {
+	dir, err := ioutil.TempDir("", "deadletters")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	dbDown := true
+	attempts := 0
+	handler := func(msg []byte) error {
+		attempts++
+		if dbDown {
+			return errors.New("db down")
+		}
+		fmt.Println("apply", string(msg))
+		return nil
+	}
+
+	queue, err := NewDeadLetterQueue(dir, 2, 0)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	results := []string{}
+	queue.OnResult = func(msg []byte, err error) {
+		results = append(results, fmt.Sprint(string(msg), " ", err))
+	}
+	consumer := queue.Consumer("devicetype", handler)
+	fmt.Println(consumer([]byte("a")), attempts)
+	fmt.Println(consumer([]byte("b")), attempts)
+
+	//only the final result of retried commands is reported
+	flaky := queue.Consumer("gateway", func(msg []byte) error {
+		attempts++
+		if attempts == 5 {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+	fmt.Println(flaky([]byte("c")), attempts, results)
+
+	for _, entry := range queue.List("devicetype") {
+		fmt.Println(entry.Id, entry.Topic, entry.Payload, entry.Error, entry.Attempts)
+	}
+	fmt.Println(len(queue.List("gateway")))
+
+	//dead letters are durable
+	queue, err = NewDeadLetterQueue(dir, 2, 0)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	queue.Consumer("devicetype", handler)
+	fmt.Println(queue.Replay("1"))
+	entry, err := queue.Get("1")
+	fmt.Println(entry.Attempts, err)
+
+	dbDown = false
+	queue.OnResult = func(msg []byte, err error) {
+		fmt.Println("result", string(msg), err)
+	}
+	fmt.Println(queue.Replay("1"))
+	fmt.Println(queue.Discard("2"))
+	fmt.Println(len(queue.List("")))
+	_, err = queue.Get("1")
+	fmt.Println(err)
+
+	//Output:
+	//<nil> 2
+	//<nil> 4
+	//<nil> 6 [a db down b db down c <nil>]
+	//1 devicetype a db down 2
+	//2 devicetype b db down 2
+	//0
+	//db down
+	//3 <nil>
+	//apply a
+	//result a <nil>
+	//<nil>
+	//<nil>
+	//0
+	//unknown dead letter: 1
+}
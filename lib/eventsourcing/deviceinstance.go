@@ -17,45 +17,75 @@
 package eventsourcing
 
 import (
-	"encoding/json"
-	"errors"
 	"log"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-type DeviceinstanceCommand struct {
-	Command        string               `json:"command"`
+//payload of PUT and DELETE commands of the device instance topic
+type DeviceInstancePayload struct {
 	Id             string               `json:"id"`
 	Owner          string               `json:"owner"`
 	DeviceInstance model.DeviceInstance `json:"device_instance"`
 }
 
-func getDeviceInstanceCommandHandler(db interfaces.Persistence) amqp_wrapper_lib.ConsumerFunc {
+func (this DeviceInstancePayload) Validate(commandType string) error {
+	switch commandType {
+	case CommandPut:
+		if this.DeviceInstance.Id == "" {
+			return missingField("device_instance.id")
+		}
+	case CommandDelete:
+		if this.Id == "" {
+			return missingField("id")
+		}
+	default:
+		return unknownCommandType(commandType)
+	}
+	return nil
+}
+
+func getDeviceInstanceCommandHandler(db interfaces.Persistence) broker.ConsumerFunc {
 	return func(msg []byte) (err error) {
 		log.Println(util.Config.DeviceInstanceTopic, string(msg))
-		command := DeviceinstanceCommand{}
-		err = json.Unmarshal(msg, &command)
+		payload := DeviceInstancePayload{}
+		command, err := ParseCommand(msg, &payload)
 		if err != nil {
-			return
+			return err
 		}
-		switch command.Command {
-		case "PUT":
-			return db.SetDeviceInstance(command.DeviceInstance)
-		case "DELETE":
-			return db.DeleteDeviceInstance(command.Id)
+		id := payload.Id
+		if command.Type == CommandPut {
+			id = payload.DeviceInstance.Id
 		}
-		return errors.New("unable to handle permission command: " + string(msg))
+		err = updateAuth(db, util.Config.DeviceInstanceTopic, command.Type, id, payload.Owner)
+		if err != nil {
+			return err
+		}
+		if command.Type == CommandPut {
+			return db.SetDeviceInstance(payload.DeviceInstance)
+		}
+		return db.DeleteDeviceInstance(payload.Id)
+	}
+}
+
+func PublishDeviceInstance(instance model.DeviceInstance, creator string) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	message, err := DeviceInstanceMessage(instance, creator, correlationId)
+	if err != nil {
+		return correlationId, err
 	}
+	return correlationId, PublishAll(message)
 }
 
-func PublishDeviceInstance(instance model.DeviceInstance, creator string) (err error) {
-	return sendEvent(util.Config.DeviceInstanceTopic, DeviceinstanceCommand{DeviceInstance: instance, Id: instance.Id, Command: "PUT", Owner: creator})
+//to publish together with other messages by PublishAll()
+func DeviceInstanceMessage(instance model.DeviceInstance, creator string, correlationId string) (OutboxMessage, error) {
+	return NewCommandMessage(util.Config.DeviceInstanceTopic, CommandPut, correlationId, DeviceInstancePayload{DeviceInstance: instance, Id: instance.Id, Owner: creator})
 }
 
-func PublishDeviceInstanceRemove(id string) (err error) {
-	return sendEvent(util.Config.DeviceInstanceTopic, DeviceinstanceCommand{Id: id, Command: "DELETE"})
+func PublishDeviceInstanceRemove(id string) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	return correlationId, sendCommand(util.Config.DeviceInstanceTopic, CommandDelete, correlationId, DeviceInstancePayload{Id: id})
 }
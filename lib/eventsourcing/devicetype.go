@@ -17,42 +17,65 @@
 package eventsourcing
 
 import (
-	"encoding/json"
-	"errors"
 	"log"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-type DeviceTypeCommand struct {
-	Command    string           `json:"command"`
+//payload of PUT and DELETE commands of the device type topic
+type DeviceTypePayload struct {
 	Id         string           `json:"id"`
 	Owner      string           `json:"owner"`
 	DeviceType model.DeviceType `json:"device_type"`
+	Reviewed   bool             `json:"reviewed,omitempty"` //PUT of a generated device type accepted by a review
 }
 
-func getDeviceTypeCommandHandler(db interfaces.Persistence) amqp_wrapper_lib.ConsumerFunc {
+func (this DeviceTypePayload) Validate(commandType string) error {
+	switch commandType {
+	case CommandPut:
+		if this.DeviceType.Id == "" {
+			return missingField("device_type.id")
+		}
+	case CommandDelete:
+		if this.Id == "" {
+			return missingField("id")
+		}
+	default:
+		return unknownCommandType(commandType)
+	}
+	return nil
+}
+
+func getDeviceTypeCommandHandler(db interfaces.Persistence) broker.ConsumerFunc {
 	return func(msg []byte) (err error) {
 		log.Println(util.Config.DeviceTypeTopic, string(msg))
-		command := DeviceTypeCommand{}
-		err = json.Unmarshal(msg, &command)
+		payload := DeviceTypePayload{}
+		command, err := ParseCommand(msg, &payload)
+		if err != nil {
+			return err
+		}
+		id := payload.Id
+		if command.Type == CommandPut {
+			id = payload.DeviceType.Id
+		}
+		err = updateAuth(db, util.Config.DeviceTypeTopic, command.Type, id, payload.Owner)
 		if err != nil {
-			return
+			return err
 		}
-		switch command.Command {
-		case "PUT":
-			err = publishMissingValueTypes(db, command.DeviceType, command.Owner)
+		if command.Type == CommandPut {
+			err = publishMissingValueTypes(db, payload.DeviceType, payload.Owner)
 			if err != nil {
 				return err
 			}
-			return db.SetDeviceType(command.DeviceType)
-		case "DELETE":
-			return db.DeleteDeviceType(command.Id)
+			if payload.Reviewed {
+				return db.SetReviewedDeviceType(payload.DeviceType)
+			}
+			return db.SetDeviceType(payload.DeviceType)
 		}
-		return errors.New("unable to handle permission command: " + string(msg))
+		return db.DeleteDeviceType(payload.Id)
 	}
 }
 
@@ -81,15 +104,33 @@ func publishMissingValueType(db interfaces.Persistence, valueType model.ValueTyp
 		return err
 	}
 	if !exists {
-		return PublishValueType(valueType, owner)
+		_, err = PublishValueType(valueType, owner)
+		return err
 	}
 	return nil
 }
 
-func PublishDeviceType(dt model.DeviceType, owner string) (err error) {
-	return sendEvent(util.Config.DeviceTypeTopic, DeviceTypeCommand{DeviceType: dt, Id: dt.Id, Command: "PUT", Owner: owner})
+func PublishDeviceType(dt model.DeviceType, owner string) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	message, err := DeviceTypeMessage(dt, owner, correlationId)
+	if err != nil {
+		return correlationId, err
+	}
+	return correlationId, PublishAll(message)
+}
+
+//to publish together with other messages by PublishAll()
+func DeviceTypeMessage(dt model.DeviceType, owner string, correlationId string) (OutboxMessage, error) {
+	return NewCommandMessage(util.Config.DeviceTypeTopic, CommandPut, correlationId, DeviceTypePayload{DeviceType: dt, Id: dt.Id, Owner: owner})
+}
+
+//publishes a generated device type accepted by a review; only these commands may clear the generated flag
+func PublishReviewedDeviceType(dt model.DeviceType) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	return correlationId, sendCommand(util.Config.DeviceTypeTopic, CommandPut, correlationId, DeviceTypePayload{DeviceType: dt, Id: dt.Id, Reviewed: true})
 }
 
-func PublishDeviceTypeRemove(id string) (err error) {
-	return sendEvent(util.Config.DeviceTypeTopic, DeviceTypeCommand{Id: id, Command: "DELETE"})
+func PublishDeviceTypeRemove(id string) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	return correlationId, sendCommand(util.Config.DeviceTypeTopic, CommandDelete, correlationId, DeviceTypePayload{Id: id})
 }
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//current version of the command envelope; messages without version are legacy commands (version 1)
+const CommandVersion = 2
+
+const CommandIssuer = "iot-device-repository"
+
+const (
+	CommandPut        = "PUT"
+	CommandDelete     = "DELETE"
+	CommandMerge      = "MERGE"
+	CommandRename     = "RENAME"
+	CommandHeartbeat  = "HEARTBEAT"
+	CommandState      = "STATE" //state change event
+	CommandSync       = "SYNC"
+	CommandClaim      = "CLAIM"      //claim token of a gateway
+	CommandCredential = "CREDENTIAL" //credential of a gateway
+	CommandRevoke     = "REVOKE"     //revocation of gateway credentials
+)
+
+//common envelope of all commands; the payload depends on topic and type
+type CommandEnvelope struct {
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	CorrelationId string          `json:"correlation_id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Issuer        string          `json:"issuer"`
+	Payload       json.RawMessage `json:"payload"`
+
+	legacyFields bool
+}
+
+//with legacyFields the fields of version 1 (command and payload fields) are added to the top level
+func (this CommandEnvelope) MarshalJSON() ([]byte, error) {
+	type envelope CommandEnvelope
+	if !this.legacyFields {
+		return json.Marshal(envelope(this))
+	}
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(this.Payload, &fields)
+	if err != nil {
+		return nil, err
+	}
+	current, err := json.Marshal(envelope(this))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(current, &fields)
+	if err != nil {
+		return nil, err
+	}
+	fields["command"], err = json.Marshal(this.Type)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+//payload of a command which can be validated
+type CommandPayload interface {
+	Validate(commandType string) error
+}
+
+//invalid commands are not retried
+type InvalidCommandError struct {
+	Reason string
+}
+
+func (this InvalidCommandError) Error() string {
+	return "invalid command: " + this.Reason
+}
+
+func IsInvalidCommand(err error) bool {
+	_, ok := err.(InvalidCommandError)
+	return ok
+}
+
+func NewCommandEnvelope(commandType string, correlationId string, payload CommandPayload) (envelope CommandEnvelope, err error) {
+	err = payload.Validate(commandType)
+	if err != nil {
+		return envelope, err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return envelope, err
+	}
+	return CommandEnvelope{
+		Type:          commandType,
+		Version:       CommandVersion,
+		CorrelationId: correlationId,
+		Timestamp:     time.Now(),
+		Issuer:        CommandIssuer,
+		Payload:       raw,
+		legacyFields:  util.Config != nil && util.Config.CommandLegacyFields == "true",
+	}, nil
+}
+
+//parses and validates the envelope and its payload; legacy commands are upgraded to the current version
+func ParseCommand(msg []byte, payload CommandPayload) (envelope CommandEnvelope, err error) {
+	version := struct {
+		Version int `json:"version"`
+	}{}
+	err = json.Unmarshal(msg, &version)
+	if err != nil {
+		return envelope, InvalidCommandError{Reason: err.Error()}
+	}
+	switch {
+	case version.Version == 0:
+		envelope, err = upgradeLegacyCommand(msg)
+	case version.Version > CommandVersion:
+		return envelope, InvalidCommandError{Reason: "unsupported version " + strconv.Itoa(version.Version)}
+	default:
+		err = json.Unmarshal(msg, &envelope)
+	}
+	if err != nil {
+		return envelope, InvalidCommandError{Reason: err.Error()}
+	}
+	if len(envelope.Payload) == 0 {
+		return envelope, InvalidCommandError{Reason: "missing payload"}
+	}
+	err = json.Unmarshal(envelope.Payload, payload)
+	if err != nil {
+		return envelope, InvalidCommandError{Reason: err.Error()}
+	}
+	return envelope, payload.Validate(envelope.Type)
+}
+
+//legacy commands contain the command type, owner and correlation id beside the payload fields
+func upgradeLegacyCommand(msg []byte) (envelope CommandEnvelope, err error) {
+	legacy := struct {
+		Command       string `json:"command"`
+		CorrelationId string `json:"correlation_id"`
+	}{}
+	err = json.Unmarshal(msg, &legacy)
+	if err != nil {
+		return envelope, err
+	}
+	if legacy.Command == "" {
+		return envelope, errors.New("missing command type")
+	}
+	return CommandEnvelope{
+		Type:          legacy.Command,
+		Version:       CommandVersion,
+		CorrelationId: legacy.CorrelationId,
+		Payload:       msg,
+	}, nil
+}
+
+func unknownCommandType(commandType string) error {
+	return InvalidCommandError{Reason: "unknown type " + commandType}
+}
+
+func missingField(field string) error {
+	return InvalidCommandError{Reason: "missing " + field}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+func Example_commandEnvelope() {
+	envelope, err := NewCommandEnvelope(CommandPut, "c1", DeviceInstancePayload{Id: "d1", Owner: "user", DeviceInstance: model.DeviceInstance{Id: "d1", Name: "lamp"}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	msg, _ := json.Marshal(envelope)
+	payload := DeviceInstancePayload{}
+	command, err := ParseCommand(msg, &payload)
+	fmt.Println(command.Type, command.Version, command.CorrelationId, command.Issuer, payload.DeviceInstance.Name, err)
+
+	//legacy commands are upgraded
+	legacy := []byte(`{"command": "DELETE", "id": "d1", "owner": "", "correlation_id": "c2"}`)
+	payload = DeviceInstancePayload{}
+	command, err = ParseCommand(legacy, &payload)
+	fmt.Println(command.Type, command.Version, command.CorrelationId, payload.Id, err)
+
+	_, err = ParseCommand([]byte(`{"type": "PUT", "version": 3, "payload": {}}`), &DeviceInstancePayload{})
+	fmt.Println(err, IsInvalidCommand(err))
+
+	_, err = ParseCommand([]byte(`{"type": "MERGE", "version": 2, "payload": {"id": "d1"}}`), &DeviceInstancePayload{})
+	fmt.Println(err)
+
+	_, err = ParseCommand([]byte(`{"type": "DELETE", "version": 2, "payload": {}}`), &GatewayPayload{})
+	fmt.Println(err)
+
+	_, err = ParseCommand([]byte(`{"type": "RENAME", "version": 2}`), &ValueTypePayload{})
+	fmt.Println(err)
+
+	_, err = NewCommandEnvelope(CommandMerge, "c3", ValueTypePayload{Id: "vt1"})
+	fmt.Println(err)
+
+	//Output:
+	//PUT 2 c1 iot-device-repository lamp <nil>
+	//DELETE 2 c2 d1 <nil>
+	//invalid command: unsupported version 3 true
+	//invalid command: unknown type MERGE
+	//invalid command: missing id
+	//invalid command: missing payload
+	//invalid command: missing duplicates
+}
+
+func Example_commandEnvelopeLegacyFields() {
+	config := util.Config
+	defer func() { util.Config = config }()
+	util.Config = &util.ConfigStruct{CommandLegacyFields: "true"}
+
+	envelope, err := NewCommandEnvelope(CommandPut, "c1", DeviceInstancePayload{Id: "d1", Owner: "user", DeviceInstance: model.DeviceInstance{Id: "d1", Name: "lamp"}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	msg, err := json.Marshal(envelope)
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal(msg, &fields)
+	keys := []string{}
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Println(keys, err)
+	fmt.Println(string(fields["command"]), string(fields["owner"]), string(fields["correlation_id"]), string(fields["version"]))
+
+	//consumers of version 2 read the envelope
+	payload := DeviceInstancePayload{}
+	command, err := ParseCommand(msg, &payload)
+	fmt.Println(command.Type, command.Version, command.CorrelationId, payload.Owner, payload.DeviceInstance.Name, err)
+
+	util.Config = &util.ConfigStruct{}
+	envelope, _ = NewCommandEnvelope(CommandDelete, "c2", DeviceInstancePayload{Id: "d1"})
+	msg, err = json.Marshal(envelope)
+	fields = map[string]json.RawMessage{}
+	json.Unmarshal(msg, &fields)
+	_, hasCommand := fields["command"]
+	_, hasId := fields["id"]
+	fmt.Println(len(fields), hasCommand, hasId, err)
+
+	//Output:
+	//[command correlation_id device_instance id issuer owner payload timestamp type version] <nil>
+	//"PUT" "user" "c1" 2
+	//PUT 2 c1 user lamp <nil>
+	//6 false false <nil>
+}
@@ -18,59 +18,294 @@ package eventsourcing
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"strings"
+	"time"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-var AmqpConn *amqp_wrapper_lib.Connection
+//created by InitEventHandling() from util.Config.Broker if not set before
+var Broker broker.Broker
+
+var outbox *Outbox
+
+var commands = NewCommandStatusRegistry(10 * time.Minute)
+
+var deadLetters *DeadLetterQueue
+
+var commandLog *CommandLog
+
+//commands published while rebuilding from the command log are already contained in the log
+var publishingSuppressed = false
 
 func InitEventHandling(db interfaces.Persistence) (err error) {
-	AmqpConn, err = amqp_wrapper_lib.Init(util.Config.AmqpUrl, []string{util.Config.DeviceInstanceTopic, util.Config.DeviceTypeTopic, util.Config.GatewayTopic, util.Config.ValueTypeTopic}, util.Config.AmqpReconnectTimeout)
+	commands = NewCommandStatusRegistry(time.Duration(util.Config.CommandStatusTTL) * time.Second)
+	outbox, err = NewOutbox(util.Config.OutboxDir, util.Config.OutboxMaxAttempts, time.Duration(util.Config.OutboxRetryInterval)*time.Second, time.Duration(util.Config.OutboxMaxRetryInterval)*time.Second, func(topic string, payload []byte) error {
+		log.Println("DEBUG: send event: ", topic, string(payload))
+		return Broker.Publish(topic, payload)
+	})
 	if err != nil {
-		log.Fatal("ERROR: while initializing amqp connection", err)
+		log.Fatal("ERROR: while initializing outbox", err)
 		return
 	}
 
+	deadLetters, err = NewDeadLetterQueue(util.Config.DeadLetterDir, util.Config.DeadLetterMaxAttempts, time.Duration(util.Config.DeadLetterRetryInterval)*time.Second)
+	if err != nil {
+		log.Fatal("ERROR: while initializing dead letters", err)
+		return
+	}
+	//only final results are recorded; failed attempts which are retried by the dead letter queue are not visible to waiting requests
+	deadLetters.OnResult = recordSharedCommandResult
+
+	if util.Config.CommandLogFile != "" {
+		commandLog, err = OpenCommandLog(util.Config.CommandLogFile)
+		if err != nil {
+			log.Fatal("ERROR: while opening command log", err)
+			return
+		}
+	}
+
+	if Broker == nil {
+		Broker, err = NewBroker(util.Config.Broker)
+		if err != nil {
+			log.Fatal("ERROR: while creating broker", err)
+			return
+		}
+	}
+	topics := []string{util.Config.DeviceInstanceTopic, util.Config.DeviceTypeTopic, util.Config.GatewayTopic, util.Config.ValueTypeTopic}
+	if util.Config.PermissionsTopic != "" {
+		topics = append(topics, util.Config.PermissionsTopic)
+	}
+	if util.Config.CommandStatusTopic != "" {
+		topics = append(topics, util.Config.CommandStatusTopic)
+	}
+	err = Broker.InitTopics(topics)
+	if err != nil {
+		log.Fatal("ERROR: while initializing broker connection", err)
+		return
+	}
+
+	if util.Config.CommandStatusTopic != "" {
+		log.Println("init command status handler")
+		//each instance records the results of commands consumed by other instances
+		err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.CommandStatusTopic+"_"+util.Config.InstanceId, util.Config.CommandStatusTopic, handleCommandResult)
+		if err != nil {
+			log.Fatal("ERROR: while initializing command status consumer", err)
+			return
+		}
+	}
+
 	log.Println("init deviceinstance event handler")
-	err = AmqpConn.Consume(util.Config.AmqpConsumerName+"_"+util.Config.DeviceInstanceTopic, util.Config.DeviceInstanceTopic, getDeviceInstanceCommandHandler(db))
+	err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.DeviceInstanceTopic, util.Config.DeviceInstanceTopic, deadLetters.Consumer(util.Config.DeviceInstanceTopic, logCommand(util.Config.DeviceInstanceTopic, getDeviceInstanceCommandHandler(db))))
 	if err != nil {
 		log.Fatal("ERROR: while initializing deviceinstance consumer", err)
 		return
 	}
 
 	log.Println("init devicetype event handler")
-	err = AmqpConn.Consume(util.Config.AmqpConsumerName+"_"+util.Config.DeviceTypeTopic, util.Config.DeviceTypeTopic, getDeviceTypeCommandHandler(db))
+	err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.DeviceTypeTopic, util.Config.DeviceTypeTopic, deadLetters.Consumer(util.Config.DeviceTypeTopic, logCommand(util.Config.DeviceTypeTopic, getDeviceTypeCommandHandler(db))))
 	if err != nil {
 		log.Fatal("ERROR: while initializing devicetype consumer", err)
 		return
 	}
 
 	log.Println("init gateway event handler")
-	err = AmqpConn.Consume(util.Config.AmqpConsumerName+"_"+util.Config.GatewayTopic, util.Config.GatewayTopic, getGatewayCommandHandler(db))
+	err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.GatewayTopic, util.Config.GatewayTopic, deadLetters.Consumer(util.Config.GatewayTopic, logCommand(util.Config.GatewayTopic, getGatewayCommandHandler(db))))
 	if err != nil {
 		log.Fatal("ERROR: while initializing event consumer", err)
 		return
 	}
 
 	log.Println("init valuetype event handler")
-	err = AmqpConn.Consume(util.Config.AmqpConsumerName+"_"+util.Config.ValueTypeTopic, util.Config.ValueTypeTopic, getValueTypeCommandHandler(db))
+	err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.ValueTypeTopic, util.Config.ValueTypeTopic, deadLetters.Consumer(util.Config.ValueTypeTopic, logCommand(util.Config.ValueTypeTopic, getValueTypeCommandHandler(db))))
 	if err != nil {
 		log.Fatal("ERROR: while initializing event consumer", err)
 		return
 	}
 
+	if util.Config.PermissionsTopic != "" && util.Config.PermissionsEngine == "embedded" {
+		log.Println("init permission event handler")
+		err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.PermissionsTopic, util.Config.PermissionsTopic, deadLetters.Consumer(util.Config.PermissionsTopic, logCommand(util.Config.PermissionsTopic, getPermissionCommandHandler(db))))
+		if err != nil {
+			log.Fatal("ERROR: while initializing permission consumer", err)
+			return
+		}
+	} else if util.Config.PermissionsTopic != "" {
+		log.Println("init permission cache invalidation")
+		//each instance has its own permission cache and consumes all permission commands; the stable name reuses the queue (or consumer group) after restarts
+		err = Broker.Consume(util.Config.AmqpConsumerName+"_"+util.Config.PermissionsTopic+"_"+util.Config.InstanceId, util.Config.PermissionsTopic, recordCommandStatus(permission.HandlePermissionCommand))
+		if err != nil {
+			log.Fatal("ERROR: while initializing permission consumer", err)
+			return
+		}
+	}
+
+	log.Println("start outbox publishing")
+	outbox.Start()
 	return
 }
 
-func sendEvent(topic string, event interface{}) error {
-	payload, err := json.Marshal(event)
+//kind is "amqp" (default), "kafka" or "memory"
+func NewBroker(kind string) (broker.Broker, error) {
+	switch kind {
+	case "", "amqp":
+		return broker.NewAmqp(util.Config.AmqpUrl, util.Config.AmqpReconnectTimeout), nil
+	case "kafka":
+		return broker.NewKafka(strings.Split(util.Config.KafkaBrokers, ",")), nil
+	case "memory":
+		return broker.NewMemory(), nil
+	}
+	return nil, errors.New("unknown broker: " + kind)
+}
+
+//appends applied commands to the command log if configured
+func logCommand(topic string, handler broker.ConsumerFunc) broker.ConsumerFunc {
+	if commandLog == nil {
+		return handler
+	}
+	return commandLog.Consumer(topic, handler)
+}
+
+//records the result of the handler by the correlation id of the consumed command
+func recordCommandStatus(handler broker.ConsumerFunc) broker.ConsumerFunc {
+	return func(msg []byte) (err error) {
+		err = handler(msg)
+		recordCommandResult(msg, err)
+		return err
+	}
+}
+
+//ok is false for messages without correlation id
+func recordCommandResult(msg []byte, err error) (correlationId string, owner string, ok bool) {
+	command := struct {
+		CorrelationId string          `json:"correlation_id"`
+		Payload       json.RawMessage `json:"payload"`
+	}{}
+	if json.Unmarshal(msg, &command) != nil || command.CorrelationId == "" {
+		return "", "", false
+	}
+	payload := struct {
+		Owner string `json:"owner"`
+	}{}
+	if json.Unmarshal(command.Payload, &payload) == nil && payload.Owner != "" {
+		owner = payload.Owner
+		commands.SetOwner(command.CorrelationId, owner)
+	}
+	commands.Record(command.CorrelationId, err)
+	return command.CorrelationId, owner, true
+}
+
+func sendCommand(topic string, commandType string, correlationId string, payload CommandPayload) error {
+	message, err := NewCommandMessage(topic, commandType, correlationId, payload)
 	if err != nil {
-		log.Println("ERROR: event marshaling:", err)
 		return err
 	}
-	log.Println("DEBUG: send amqp event: ", topic, string(payload))
-	return AmqpConn.Publish(topic, payload)
+	return PublishAll(message)
+}
+
+//wraps the payload in a command envelope; to publish together with other messages by PublishAll()
+func NewCommandMessage(topic string, commandType string, correlationId string, payload CommandPayload) (message OutboxMessage, err error) {
+	envelope, err := NewCommandEnvelope(commandType, correlationId, payload)
+	if err != nil {
+		return message, err
+	}
+	return NewOutboxMessage(topic, correlationId, envelope)
+}
+
+//records all messages in one outbox entry; they will be published in order
+//messages with the same correlation id are applied when all of them are consumed
+func PublishAll(messages ...OutboxMessage) (err error) {
+	if publishingSuppressed {
+		return nil
+	}
+	if outbox == nil {
+		return errors.New("outbox not initialized")
+	}
+	counts := map[string]int{}
+	for _, message := range messages {
+		if message.CorrelationId != "" {
+			counts[message.CorrelationId]++
+		}
+	}
+	for id, count := range counts {
+		commands.Register(id, count)
+	}
+	err = outbox.Record(messages...)
+	if err != nil {
+		for id := range counts {
+			commands.Forget(id)
+		}
+	}
+	return err
+}
+
+//ok is false for unknown or expired correlation ids
+func GetCommandStatus(correlationId string) (status CommandStatus, ok bool) {
+	return commands.Get(correlationId)
+}
+
+//the owner may read the status of the command by GET /commands/:id
+func SetCommandOwner(correlationId string, owner string) {
+	commands.SetOwner(correlationId, owner)
+}
+
+//waits until the command is applied or the timeout is reached; results of other instances are only known with CommandStatusTopic
+func WaitForCommand(correlationId string, timeout time.Duration) (status CommandStatus, ok bool) {
+	return commands.Wait(correlationId, timeout)
+}
+
+//state == "" lists all entries
+func ListOutbox(state string) ([]OutboxEntry, error) {
+	if outbox == nil {
+		return nil, errors.New("outbox not initialized")
+	}
+	return outbox.List(state), nil
+}
+
+func RetryOutboxEntry(id string) error {
+	if outbox == nil {
+		return errors.New("outbox not initialized")
+	}
+	return outbox.Retry(id)
+}
+
+func DiscardOutboxEntry(id string) error {
+	if outbox == nil {
+		return errors.New("outbox not initialized")
+	}
+	return outbox.Discard(id)
+}
+
+//topic == "" lists all dead letters
+func ListDeadLetters(topic string) ([]DeadLetter, error) {
+	if deadLetters == nil {
+		return nil, errors.New("dead letters not initialized")
+	}
+	return deadLetters.List(topic), nil
+}
+
+func GetDeadLetter(id string) (DeadLetter, error) {
+	if deadLetters == nil {
+		return DeadLetter{}, errors.New("dead letters not initialized")
+	}
+	return deadLetters.Get(id)
+}
+
+func ReplayDeadLetter(id string) error {
+	if deadLetters == nil {
+		return errors.New("dead letters not initialized")
+	}
+	return deadLetters.Replay(id)
+}
+
+func DiscardDeadLetter(id string) error {
+	if deadLetters == nil {
+		return errors.New("dead letters not initialized")
+	}
+	return deadLetters.Discard(id)
 }
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+type dbMock struct {
+	interfaces.Persistence
+	mux       sync.Mutex
+	instances map[string]model.DeviceInstance
+}
+
+func (this *dbMock) SetDeviceInstance(instance model.DeviceInstance) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if instance.Name == "" {
+		return errors.New("missing name")
+	}
+	this.instances[instance.Id] = instance
+	return nil
+}
+
+func (this *dbMock) DeleteDeviceInstance(id string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.instances, id)
+	return nil
+}
+
+//starts the event handling with the in-process broker; stop() closes the broker and removes the temporary directories
+func startTestEventHandling(db interfaces.Persistence, deadLetterMaxAttempts int64) (stop func(), err error) {
+	dir, err := ioutil.TempDir("", "eventsourcing")
+	if err != nil {
+		return nil, err
+	}
+	util.Config = &util.ConfigStruct{
+		Broker:              "memory",
+		AmqpConsumerName:    "test",
+		DeviceInstanceTopic: "deviceinstance",
+		DeviceTypeTopic:     "devicetype",
+		GatewayTopic:        "gateway",
+		ValueTypeTopic:      "valuetype",
+		OutboxDir:           filepath.Join(dir, "outbox"),
+		DeadLetterDir:       filepath.Join(dir, "deadletters"),
+	}
+	util.HandleDefaultValues(util.Config)
+	util.Config.DeadLetterMaxAttempts = deadLetterMaxAttempts
+	Broker = nil
+	err = InitEventHandling(db)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return func() {
+		Broker.Close()
+		Broker = nil
+		os.RemoveAll(dir)
+	}, nil
+}
+
+func (this *dbMock) getInstance(id string) model.DeviceInstance {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.instances[id]
+}
+
+func (this *dbMock) countInstances() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.instances)
+}
+
+func Example_inProcessBroker() {
+	db := &dbMock{instances: map[string]model.DeviceInstance{}}
+	stop, err := startTestEventHandling(db, 1)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer stop()
+	_, ok := Broker.(*broker.Memory)
+	fmt.Println(ok)
+
+	correlationId, err := PublishDeviceInstance(model.DeviceInstance{Id: "device1", Name: "lamp"}, "user")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	status, _ := WaitForCommand(correlationId, time.Second)
+	fmt.Println(status.State, db.getInstance("device1").Name)
+
+	correlationId, _ = PublishDeviceInstance(model.DeviceInstance{Id: "device2"}, "user")
+	status, _ = WaitForCommand(correlationId, time.Second)
+	fmt.Println(status.State, status.Error)
+
+	//commands of a topic are consumed in order; the failed command is a dead letter now
+	correlationId, _ = PublishDeviceInstanceRemove("device1")
+	status, _ = WaitForCommand(correlationId, time.Second)
+	fmt.Println(status.State, db.countInstances())
+	letters, _ := ListDeadLetters("deviceinstance")
+	fmt.Println(len(letters))
+
+	//Output:
+	//true
+	//applied lamp
+	//failed missing name
+	//applied 0
+	//1
+}
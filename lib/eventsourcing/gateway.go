@@ -17,59 +17,133 @@
 package eventsourcing
 
 import (
-	"encoding/json"
-	"errors"
 	"log"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-type GatewayCommand struct {
-	Command string   `json:"command"`
-	Id      string   `json:"id"`
-	Owner   string   `json:"owner"`
-	Name    string   `json:"name"`
-	Hash    string   `json:"hash"`
-	Devices []string `json:"devices"`
+//payload of PUT, DELETE, HEARTBEAT, STATE, SYNC, CLAIM, CREDENTIAL and REVOKE commands of the gateway topic
+type GatewayPayload struct {
+	Id         string                   `json:"id"`
+	Owner      string                   `json:"owner"`
+	Name       string                   `json:"name"`
+	Hash       string                   `json:"hash"`
+	Devices    []string                 `json:"devices"`
+	Gateways   []string                 `json:"gateways"`             //null keeps the connected gateways
+	Connection *model.GatewayConnection `json:"connection,omitempty"` //HEARTBEAT and STATE
+	Sync       *model.GatewaySyncState  `json:"sync,omitempty"`       //SYNC
+	Claim      *model.GatewayClaimToken `json:"claim,omitempty"`      //CLAIM; CREDENTIAL if the credential is claimed by the token
+	Credential *model.GatewayCredential `json:"credential,omitempty"` //CREDENTIAL
+	Revoke     []string                 `json:"revoke,omitempty"`     //credential ids; CREDENTIAL (rotation) and REVOKE
 }
 
-func getGatewayCommandHandler(db interfaces.Persistence) amqp_wrapper_lib.ConsumerFunc {
+func (this GatewayPayload) Validate(commandType string) error {
+	switch commandType {
+	case CommandPut, CommandDelete:
+	case CommandHeartbeat, CommandState:
+		if this.Connection == nil {
+			return missingField("connection")
+		}
+		if this.Connection.LastSeen == "" {
+			return missingField("connection.last_seen")
+		}
+	case CommandSync:
+		if this.Sync == nil {
+			return missingField("sync")
+		}
+	case CommandClaim:
+		if this.Claim == nil {
+			return missingField("claim")
+		}
+		if this.Claim.Id == "" || this.Claim.Hash == "" || this.Claim.Expires == "" {
+			return missingField("claim.id, claim.hash or claim.expires")
+		}
+	case CommandCredential:
+		if this.Credential == nil {
+			return missingField("credential")
+		}
+		if this.Credential.Id == "" {
+			return missingField("credential.id")
+		}
+		err := validateGatewayCredential(*this.Credential)
+		if err != nil {
+			return err
+		}
+	case CommandRevoke:
+		if len(this.Revoke) == 0 {
+			return missingField("revoke")
+		}
+	default:
+		return unknownCommandType(commandType)
+	}
+	if this.Id == "" {
+		return missingField("id")
+	}
+	return nil
+}
+
+func getGatewayCommandHandler(db interfaces.Persistence) broker.ConsumerFunc {
 	return func(msg []byte) (err error) {
 		log.Println(util.Config.GatewayTopic, string(msg))
-		command := GatewayCommand{}
-		err = json.Unmarshal(msg, &command)
+		payload := GatewayPayload{}
+		command, err := ParseCommand(msg, &payload)
 		if err != nil {
-			return
+			return err
 		}
-		switch command.Command {
-		case "PUT":
-			return db.SetGatway(command.Id, command.Name, command.Hash, command.Devices)
-		case "DELETE":
-			return db.DeleteGateway(command.Id)
+		switch command.Type {
+		case CommandHeartbeat:
+			//published by instances before heartbeats were stored directly
+			_, err = applyGatewayHeartbeat(db, payload.Id, *payload.Connection)
+			return err
+		case CommandState:
+			return applyGatewayState(db, payload.Id, *payload.Connection)
+		case CommandSync:
+			state := *payload.Sync
+			state.Id = payload.Id
+			return db.SetGatewaySyncState(state)
+		case CommandClaim:
+			return applyGatewayClaim(db, payload.Id, *payload.Claim)
+		case CommandCredential:
+			return applyGatewayCredential(db, payload.Id, *payload.Credential, payload.Claim, payload.Revoke)
+		case CommandRevoke:
+			return applyGatewayRevoke(db, payload.Id, payload.Revoke)
 		}
-		return errors.New("unable to handle permission command: " + string(msg))
+		err = updateAuth(db, util.Config.GatewayTopic, command.Type, payload.Id, payload.Owner)
+		if err != nil {
+			return err
+		}
+		if command.Type == CommandPut {
+			return db.SetGatway(payload.Id, payload.Name, payload.Hash, payload.Devices, payload.Gateways)
+		}
+		return db.DeleteGateway(payload.Id)
 	}
 }
 
-func PublishGateway(gw model.Gateway, owner string) (err error) {
+func PublishGateway(gw model.Gateway, owner string) (correlationId string, err error) {
 	devices := []string{}
 	for _, device := range gw.Devices {
 		devices = append(devices, device.Id)
 	}
-	return sendEvent(util.Config.GatewayTopic, GatewayCommand{Command: "PUT", Id: gw.Id, Name: gw.Name, Hash: gw.Hash, Owner: owner, Devices: devices})
+	return PublishGatewayCommand(CommandPut, GatewayPayload{Id: gw.Id, Name: gw.Name, Hash: gw.Hash, Owner: owner, Devices: devices, Gateways: gw.Gateways})
+}
+
+func PublishGatewayRef(gw model.GatewayRef, name string, owner string) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandPut, GatewayPayload{Id: gw.Id, Name: name, Hash: gw.Hash, Owner: owner, Devices: gw.Devices, Gateways: gw.Gateways})
 }
 
-func PublishGatewayRef(gw model.GatewayRef, name string, owner string) (err error) {
-	return sendEvent(util.Config.GatewayTopic, GatewayCommand{Command: "PUT", Id: gw.Id, Name: name, Hash: gw.Hash, Owner: owner, Devices: gw.Devices})
+//stores the state delivered to the gateway by a sync
+func PublishGatewaySync(state model.GatewaySyncState) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandSync, GatewayPayload{Id: state.Id, Sync: &state})
 }
 
-func PublishGatewayCommand(gw GatewayCommand) (err error) {
-	return sendEvent(util.Config.GatewayTopic, gw)
+func PublishGatewayCommand(commandType string, gw GatewayPayload) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	return correlationId, sendCommand(util.Config.GatewayTopic, commandType, correlationId, gw)
 }
 
-func PublisGatewayRemove(id string) (err error) {
-	return sendEvent(util.Config.GatewayTopic, GatewayCommand{Id: id, Command: "DELETE"})
+func PublisGatewayRemove(id string) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandDelete, GatewayPayload{Id: id})
 }
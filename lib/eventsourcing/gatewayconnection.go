@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"log"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//stores last seen and version; gateways which are not online publish a state change
+//correlationId is the id of the published STATE command or empty if the gateway was already online
+func applyGatewayHeartbeat(db interfaces.Persistence, id string, heartbeat model.GatewayConnection) (correlationId string, err error) {
+	name, err := db.GetGatewayName(id)
+	if err != nil || name == "" {
+		return "", err
+	}
+	current, err := db.GetGatewayConnection(id)
+	if err != nil {
+		return "", err
+	}
+	if !isLater(heartbeat.LastSeen, current.LastSeen) {
+		//outdated heartbeat
+		return "", nil
+	}
+	next := current
+	next.Id = id
+	next.LastSeen = heartbeat.LastSeen
+	if heartbeat.Version != "" {
+		next.Version = heartbeat.Version
+	}
+	err = db.SetGatewayConnection(next)
+	if err != nil {
+		return "", err
+	}
+	if !current.Online {
+		return PublishGatewayState(id, true, heartbeat.LastSeen)
+	}
+	return "", nil
+}
+
+//offline states are ignored if a heartbeat was received after the timeout was detected
+func applyGatewayState(db interfaces.Persistence, id string, state model.GatewayConnection) (err error) {
+	current, err := db.GetGatewayConnection(id)
+	if err != nil {
+		return err
+	}
+	if current.Online == state.Online {
+		return nil
+	}
+	if !state.Online && isLater(current.LastSeen, state.LastSeen) {
+		return nil
+	}
+	current.Id = id
+	current.Online = state.Online
+	return db.SetGatewayConnection(current)
+}
+
+//compares RFC3339 timestamps; every timestamp is later than a empty one
+func isLater(a string, b string) bool {
+	if b == "" {
+		return a != ""
+	}
+	aTime, aErr := time.Parse(time.RFC3339, a)
+	bTime, bErr := time.Parse(time.RFC3339, b)
+	if aErr != nil || bErr != nil {
+		return a > b
+	}
+	return aTime.After(bTime)
+}
+
+//heartbeats are stored by the receiving instance; only the resulting state change is published on the gateway topic
+//correlationId is the id of the published STATE command or empty if the gateway was already online
+func RecordGatewayHeartbeat(db interfaces.Persistence, id string, version string) (correlationId string, err error) {
+	connection := model.GatewayConnection{Id: id, Online: true, LastSeen: time.Now().UTC().Format(time.RFC3339), Version: version}
+	return applyGatewayHeartbeat(db, id, connection)
+}
+
+//lastSeen is the last heartbeat known while deciding the state
+func PublishGatewayState(id string, online bool, lastSeen string) (correlationId string, err error) {
+	connection := model.GatewayConnection{Id: id, Online: online, LastSeen: lastSeen}
+	return PublishGatewayCommand(CommandState, GatewayPayload{Id: id, Connection: &connection})
+}
+
+//publishes offline states for online gateways without heartbeat since timeout
+func CheckGatewayConnections(db interfaces.Persistence, timeout time.Duration, now time.Time) (err error) {
+	connections, err := db.GetOnlineGatewayConnections()
+	if err != nil {
+		return err
+	}
+	for _, connection := range connections {
+		lastSeen, parseErr := time.Parse(time.RFC3339, connection.LastSeen)
+		if parseErr == nil && now.Sub(lastSeen) <= timeout {
+			continue
+		}
+		_, tempErr := PublishGatewayState(connection.Id, false, connection.LastSeen)
+		if tempErr != nil {
+			err = tempErr
+		}
+	}
+	return err
+}
+
+//only the instance named by GatewayConnectionCheckInstance checks the connections
+func StartGatewayConnectionCheck(db interfaces.Persistence) {
+	timeout := time.Duration(util.Config.GatewayHeartbeatTimeout) * time.Second
+	if timeout <= 0 {
+		log.Println("gateway connection check disabled")
+		return
+	}
+	if util.Config.GatewayConnectionCheckInstance != util.Config.InstanceId {
+		log.Println("gateway connection check runs on instance", util.Config.GatewayConnectionCheckInstance)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			err := CheckGatewayConnections(db, timeout, now)
+			if err != nil {
+				log.Println("ERROR: gateway connection check:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+type gatewayDbMock struct {
+	interfaces.Persistence
+	mux         sync.Mutex
+	connections map[string]model.GatewayConnection
+}
+
+func (this *gatewayDbMock) GetGatewayName(id string) (string, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.connections[id]; ok {
+		return "gateway " + id, nil
+	}
+	return "", nil
+}
+
+func (this *gatewayDbMock) GetGatewayConnection(id string) (model.GatewayConnection, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.connections[id], nil
+}
+
+func (this *gatewayDbMock) SetGatewayConnection(connection model.GatewayConnection) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.connections[connection.Id] = connection
+	return nil
+}
+
+func (this *gatewayDbMock) GetOnlineGatewayConnections() (result []model.GatewayConnection, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, connection := range this.connections {
+		if connection.Online {
+			result = append(result, connection)
+		}
+	}
+	return
+}
+
+func (this *gatewayDbMock) getConnection(id string) (connection model.GatewayConnection, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	connection, ok = this.connections[id]
+	return
+}
+
+//waits until the state changes are consumed
+func (this *gatewayDbMock) waitForOnline(id string, online bool) model.GatewayConnection {
+	for i := 0; i < 100; i++ {
+		connection, _ := this.GetGatewayConnection(id)
+		if connection.Online == online {
+			return connection
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	connection, _ := this.GetGatewayConnection(id)
+	return connection
+}
+
+func Example_gatewayConnection() {
+	db := &gatewayDbMock{connections: map[string]model.GatewayConnection{"gw1": {Id: "gw1"}}}
+	stop, err := startTestEventHandling(db, 0)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer stop()
+
+	//the heartbeat is stored directly; the state change is published
+	correlationId, err := RecordGatewayHeartbeat(db, "gw1", "1.0")
+	connection, _ := db.getConnection("gw1")
+	fmt.Println(err, connection.Online, connection.Version, connection.LastSeen != "")
+	status, _ := WaitForCommand(correlationId, time.Second)
+	connection = db.waitForOnline("gw1", true)
+	fmt.Println(status.State, connection.Online)
+
+	//gateways which are online publish no command; last_seen has a resolution of seconds
+	time.Sleep(time.Second)
+	correlationId, err = RecordGatewayHeartbeat(db, "gw1", "1.1")
+	connection, _ = db.getConnection("gw1")
+	fmt.Println(correlationId == "", err, connection.Version)
+
+	//heartbeats of unknown gateways are ignored
+	correlationId, err = RecordGatewayHeartbeat(db, "unknown", "1.0")
+	_, known := db.getConnection("unknown")
+	fmt.Println(correlationId == "", err, known)
+
+	err = CheckGatewayConnections(db, time.Minute, time.Now())
+	fmt.Println(err, db.waitForOnline("gw1", true).Online)
+
+	err = CheckGatewayConnections(db, time.Minute, time.Now().Add(2*time.Minute))
+	fmt.Println(err, db.waitForOnline("gw1", false).Online)
+
+	//offline state decided before the last heartbeat
+	db.SetGatewayConnection(model.GatewayConnection{Id: "gw1", Online: true, LastSeen: "2018-01-01T10:00:00Z"})
+	err = applyGatewayState(db, "gw1", model.GatewayConnection{Online: false, LastSeen: "2018-01-01T09:00:00Z"})
+	connection, _ = db.getConnection("gw1")
+	fmt.Println(err, connection.Online)
+
+	//Output:
+	//<nil> false 1.0 true
+	//applied true
+	//true <nil> 1.1
+	//true <nil> false
+	//<nil> true
+	//<nil> false
+	//<nil> true
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func validateGatewayCredential(credential model.GatewayCredential) error {
+	switch credential.Kind {
+	case model.GatewayCredentialKey:
+		if credential.Hash == "" {
+			return missingField("credential.hash")
+		}
+	case model.GatewayCredentialJwt:
+		if credential.Subject == "" {
+			return missingField("credential.subject")
+		}
+	default:
+		return InvalidCommandError{Reason: "unknown credential kind " + credential.Kind}
+	}
+	return nil
+}
+
+//replaces the claim token of the gateway; ignored if the gateway does not exist
+func applyGatewayClaim(db interfaces.Persistence, id string, token model.GatewayClaimToken) (err error) {
+	name, err := db.GetGatewayName(id)
+	if err != nil || name == "" {
+		return err
+	}
+	token.Gateway = id
+	return db.SetGatewayClaimToken(token)
+}
+
+//stores the credential and removes the revoked credentials of the gateway;
+//a credential claimed by a token is rejected if the token was already redeemed (one-time use) or is expired
+func applyGatewayCredential(db interfaces.Persistence, id string, credential model.GatewayCredential, claim *model.GatewayClaimToken, revoke []string) (err error) {
+	name, err := db.GetGatewayName(id)
+	if err != nil || name == "" {
+		return err
+	}
+	if credential.Kind == model.GatewayCredentialJwt {
+		current, err := db.GetGatewayCredentialBySubject(credential.Subject)
+		if err != nil {
+			return err
+		}
+		if current.Id != "" && current.Gateway != id {
+			return InvalidCommandError{Reason: "subject " + credential.Subject + " is already registered for gateway " + current.Gateway}
+		}
+	}
+	if claim != nil {
+		err = redeemGatewayClaimToken(db, id, claim.Id, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	err = applyGatewayRevoke(db, id, revoke)
+	if err != nil {
+		return err
+	}
+	credential.Gateway = id
+	return db.SetGatewayCredential(credential)
+}
+
+//removes the claim token; unknown (already redeemed) and expired tokens are invalid
+func redeemGatewayClaimToken(db interfaces.Persistence, gatewayId string, tokenId string, now time.Time) (err error) {
+	tokens, err := db.GetGatewayClaimTokens(gatewayId)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.Id == tokenId {
+			if !ClaimTokenIsValid(token, now) {
+				return InvalidCommandError{Reason: "claim token expired"}
+			}
+			return db.DeleteGatewayClaimTokens(gatewayId)
+		}
+	}
+	return InvalidCommandError{Reason: "claim token already redeemed"}
+}
+
+func ClaimTokenIsValid(token model.GatewayClaimToken, now time.Time) bool {
+	expires, err := time.Parse(time.RFC3339, token.Expires)
+	return err == nil && now.Before(expires)
+}
+
+//credentials of other gateways are ignored
+func applyGatewayRevoke(db interfaces.Persistence, id string, credentialIds []string) (err error) {
+	for _, credentialId := range credentialIds {
+		credential, err := db.GetGatewayCredential(credentialId)
+		if err != nil {
+			return err
+		}
+		if credential.Gateway != id {
+			continue
+		}
+		err = db.DeleteGatewayCredential(credentialId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PublishGatewayClaimToken(token model.GatewayClaimToken) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandClaim, GatewayPayload{Id: token.Gateway, Claim: &token})
+}
+
+//claim is the redeemed token (may be nil); revoke lists the credentials replaced by the new credential
+func PublishGatewayCredential(credential model.GatewayCredential, claim *model.GatewayClaimToken, revoke []string) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandCredential, GatewayPayload{Id: credential.Gateway, Credential: &credential, Claim: claim, Revoke: revoke})
+}
+
+func PublishGatewayRevoke(gatewayId string, credentialIds []string) (correlationId string, err error) {
+	return PublishGatewayCommand(CommandRevoke, GatewayPayload{Id: gatewayId, Revoke: credentialIds})
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+type credentialDbMock struct {
+	interfaces.Persistence
+	gateways    map[string]bool
+	tokens      map[string]model.GatewayClaimToken
+	credentials map[string]model.GatewayCredential
+}
+
+func (this *credentialDbMock) GetGatewayName(id string) (string, error) {
+	if this.gateways[id] {
+		return "gateway " + id, nil
+	}
+	return "", nil
+}
+
+func (this *credentialDbMock) GetGatewayClaimTokens(gatewayId string) (result []model.GatewayClaimToken, err error) {
+	for _, token := range this.tokens {
+		if token.Gateway == gatewayId {
+			result = append(result, token)
+		}
+	}
+	return
+}
+
+func (this *credentialDbMock) SetGatewayClaimToken(token model.GatewayClaimToken) error {
+	this.DeleteGatewayClaimTokens(token.Gateway)
+	this.tokens[token.Id] = token
+	return nil
+}
+
+func (this *credentialDbMock) DeleteGatewayClaimTokens(gatewayId string) error {
+	for id, token := range this.tokens {
+		if token.Gateway == gatewayId {
+			delete(this.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (this *credentialDbMock) GetGatewayCredential(id string) (model.GatewayCredential, error) {
+	return this.credentials[id], nil
+}
+
+func (this *credentialDbMock) GetGatewayCredentialBySubject(subject string) (model.GatewayCredential, error) {
+	for _, credential := range this.credentials {
+		if credential.Kind == model.GatewayCredentialJwt && credential.Subject == subject {
+			return credential, nil
+		}
+	}
+	return model.GatewayCredential{}, nil
+}
+
+func (this *credentialDbMock) SetGatewayCredential(credential model.GatewayCredential) error {
+	this.credentials[credential.Id] = credential
+	return nil
+}
+
+func (this *credentialDbMock) DeleteGatewayCredential(id string) error {
+	delete(this.credentials, id)
+	return nil
+}
+
+func Example_gatewayCredential() {
+	db := &credentialDbMock{
+		gateways:    map[string]bool{"gw1": true, "gw2": true},
+		tokens:      map[string]model.GatewayClaimToken{},
+		credentials: map[string]model.GatewayCredential{},
+	}
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	token := model.GatewayClaimToken{Id: "t1", Hash: "h1", Expires: expires}
+	fmt.Println(applyGatewayClaim(db, "gw1", token), len(db.tokens))
+
+	//a new token replaces the old one
+	fmt.Println(applyGatewayClaim(db, "gw1", model.GatewayClaimToken{Id: "t2", Hash: "h2", Expires: expires}), len(db.tokens), db.tokens["t2"].Gateway)
+
+	//claim tokens of unknown gateways are ignored
+	fmt.Println(applyGatewayClaim(db, "unknown", token), len(db.tokens))
+
+	//claim tokens can be redeemed once
+	key := model.GatewayCredential{Id: "c1", Kind: model.GatewayCredentialKey, Hash: "k1"}
+	fmt.Println(applyGatewayCredential(db, "gw1", key, &model.GatewayClaimToken{Id: "t2"}, nil), len(db.tokens), db.credentials["c1"].Gateway)
+	second := model.GatewayCredential{Id: "c2", Kind: model.GatewayCredentialKey, Hash: "k2"}
+	err := applyGatewayCredential(db, "gw1", second, &model.GatewayClaimToken{Id: "t2"}, nil)
+	_, stored := db.credentials["c2"]
+	fmt.Println(err, IsInvalidCommand(err), stored)
+
+	//expired claim tokens
+	applyGatewayClaim(db, "gw2", model.GatewayClaimToken{Id: "t3", Hash: "h3", Expires: "2018-01-01T10:00:00Z"})
+	err = applyGatewayCredential(db, "gw2", model.GatewayCredential{Id: "c6", Kind: model.GatewayCredentialKey, Hash: "k6"}, &model.GatewayClaimToken{Id: "t3"}, nil)
+	_, stored = db.credentials["c6"]
+	fmt.Println(err, stored, len(db.tokens))
+
+	//rotation
+	fmt.Println(applyGatewayCredential(db, "gw1", second, nil, []string{"c1"}), len(db.credentials), db.credentials["c2"].Gateway)
+
+	//jwt subjects belong to one gateway
+	subject := model.GatewayCredential{Id: "c3", Kind: model.GatewayCredentialJwt, Subject: "sub"}
+	fmt.Println(applyGatewayCredential(db, "gw1", subject, nil, nil), len(db.credentials))
+	err = applyGatewayCredential(db, "gw2", model.GatewayCredential{Id: "c4", Kind: model.GatewayCredentialJwt, Subject: "sub"}, nil, nil)
+	fmt.Println(err, IsInvalidCommand(err), len(db.credentials))
+
+	//credentials of other gateways are not revoked
+	fmt.Println(applyGatewayRevoke(db, "gw2", []string{"c2", "c3"}), len(db.credentials))
+	fmt.Println(applyGatewayRevoke(db, "gw1", []string{"c2", "c3"}), len(db.credentials))
+
+	fmt.Println(GatewayPayload{Id: "gw1", Credential: &model.GatewayCredential{Id: "c5", Kind: model.GatewayCredentialKey}}.Validate(CommandCredential))
+	fmt.Println(GatewayPayload{Id: "gw1"}.Validate(CommandRevoke))
+
+	//Output:
+	//<nil> 1
+	//<nil> 1 gw1
+	//<nil> 1
+	//<nil> 0 gw1
+	//invalid command: claim token already redeemed true false
+	//invalid command: claim token expired false 1
+	//<nil> 1 gw1
+	//<nil> 2
+	//invalid command: subject sub is already registered for gateway gw1 true 2
+	//<nil> 2
+	//<nil> 0
+	//invalid command: missing credential.hash
+	//invalid command: missing revoke
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxFailed  = "failed"
+)
+
+type OutboxMessage struct {
+	Topic         string          `json:"topic"`
+	CorrelationId string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+//messages of one entry are published in order; Published counts the already published messages
+type OutboxEntry struct {
+	Id          string          `json:"id"`
+	Sequence    int64           `json:"sequence"`
+	Messages    []OutboxMessage `json:"messages"`
+	Published   int             `json:"published"`
+	State       string          `json:"state"`
+	Attempts    int64           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Created     time.Time       `json:"created"`
+	NextAttempt time.Time       `json:"next_attempt"`
+}
+
+//durable local store of commands which are not yet published
+type Outbox struct {
+	dir              string
+	maxAttempts      int64
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	publish          func(topic string, payload []byte) error
+
+	mux      sync.Mutex
+	entries  []OutboxEntry //sorted by sequence
+	sequence int64
+	inFlight string
+	signal   chan bool
+}
+
+func NewOutbox(dir string, maxAttempts int64, retryInterval time.Duration, maxRetryInterval time.Duration, publish func(topic string, payload []byte) error) (outbox *Outbox, err error) {
+	outbox = &Outbox{
+		dir:              dir,
+		maxAttempts:      maxAttempts,
+		retryInterval:    retryInterval,
+		maxRetryInterval: maxRetryInterval,
+		publish:          publish,
+		signal:           make(chan bool, 1),
+	}
+	if dir == "" {
+		return outbox, errors.New("missing outbox directory")
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return outbox, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return outbox, err
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return outbox, err
+		}
+		entry := OutboxEntry{}
+		err = json.Unmarshal(content, &entry)
+		if err != nil {
+			log.Println("ERROR: unable to read outbox entry", file, err)
+			continue
+		}
+		outbox.entries = append(outbox.entries, entry)
+		if entry.Sequence > outbox.sequence {
+			outbox.sequence = entry.Sequence
+		}
+	}
+	sort.Slice(outbox.entries, func(i, j int) bool {
+		return outbox.entries[i].Sequence < outbox.entries[j].Sequence
+	})
+	if len(outbox.entries) > 0 {
+		log.Println("WARNING: unpublished outbox entries found:", len(outbox.entries))
+	}
+	return outbox, nil
+}
+
+func (this *Outbox) filename(entry OutboxEntry) string {
+	return filepath.Join(this.dir, fmt.Sprintf("%020d.json", entry.Sequence))
+}
+
+func (this *Outbox) store(entry OutboxEntry) (err error) {
+	return storeJson(this.filename(entry), entry)
+}
+
+//write to temporary file and rename to prevent partial entries
+func storeJson(filename string, value interface{}) (err error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(filename + ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filename + ".tmp")
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+func (this *Outbox) index(id string) int {
+	for index, entry := range this.entries {
+		if entry.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+func (this *Outbox) notify() {
+	select {
+	case this.signal <- true:
+	default:
+	}
+}
+
+//stores the messages as one entry; returns after the entry is persisted
+func (this *Outbox) Record(messages ...OutboxMessage) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	sequence := this.sequence + 1
+	now := time.Now()
+	entry := OutboxEntry{
+		Id:          strconv.FormatInt(sequence, 10),
+		Sequence:    sequence,
+		Messages:    messages,
+		State:       OutboxPending,
+		Created:     now,
+		NextAttempt: now,
+	}
+	err = this.store(entry)
+	if err != nil {
+		return err
+	}
+	this.sequence = sequence
+	this.entries = append(this.entries, entry)
+	this.notify()
+	return nil
+}
+
+//state == "" lists all entries
+func (this *Outbox) List(state string) (result []OutboxEntry) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []OutboxEntry{}
+	for _, entry := range this.entries {
+		if state == "" || entry.State == state {
+			result = append(result, entry)
+		}
+	}
+	return
+}
+
+func (this *Outbox) Retry(id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	index := this.index(id)
+	if index == -1 {
+		return errors.New("unknown outbox entry: " + id)
+	}
+	entry := this.entries[index]
+	if entry.State != OutboxFailed {
+		return errors.New("outbox entry is not failed: " + id)
+	}
+	entry.State = OutboxPending
+	entry.Attempts = 0
+	entry.NextAttempt = time.Now()
+	err = this.store(entry)
+	if err != nil {
+		return err
+	}
+	this.entries[index] = entry
+	this.notify()
+	return nil
+}
+
+func (this *Outbox) Discard(id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	index := this.index(id)
+	if index == -1 {
+		return errors.New("unknown outbox entry: " + id)
+	}
+	if this.inFlight == id {
+		return errors.New("outbox entry is currently published: " + id)
+	}
+	err = os.Remove(this.filename(this.entries[index]))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	this.entries = append(this.entries[:index], this.entries[index+1:]...)
+	return nil
+}
+
+func (this *Outbox) backoff(attempts int64) (result time.Duration) {
+	result = this.retryInterval
+	for i := int64(1); i < attempts && result < this.maxRetryInterval; i++ {
+		result = result * 2
+	}
+	if result > this.maxRetryInterval {
+		result = this.maxRetryInterval
+	}
+	return
+}
+
+//publishes the oldest pending entry; later entries wait to preserve the order of commands
+//returns the duration until the next entry is due or -1 if no entry is pending
+func (this *Outbox) processNext(now time.Time) (wait time.Duration) {
+	this.mux.Lock()
+	index := -1
+	for i, entry := range this.entries {
+		if entry.State == OutboxPending {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		this.mux.Unlock()
+		return -1
+	}
+	entry := this.entries[index]
+	if entry.NextAttempt.After(now) {
+		this.mux.Unlock()
+		return entry.NextAttempt.Sub(now)
+	}
+	this.inFlight = entry.Id
+	this.mux.Unlock()
+
+	var err error
+	for entry.Published < len(entry.Messages) && err == nil {
+		message := entry.Messages[entry.Published]
+		err = this.publish(message.Topic, message.Payload)
+		if err == nil {
+			entry.Published++
+		}
+	}
+
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.inFlight = ""
+	index = this.index(entry.Id)
+	if err == nil {
+		removeErr := os.Remove(this.filename(entry))
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Println("ERROR: unable to remove published outbox entry", entry.Id, removeErr)
+		}
+		this.entries = append(this.entries[:index], this.entries[index+1:]...)
+		return 0
+	}
+	entry.Attempts++
+	entry.LastError = err.Error()
+	entry.NextAttempt = now.Add(this.backoff(entry.Attempts))
+	if this.maxAttempts > 0 && entry.Attempts >= this.maxAttempts {
+		entry.State = OutboxFailed
+		log.Println("ERROR: outbox entry failed after", entry.Attempts, "attempts:", entry.Id, err)
+	} else {
+		log.Println("WARNING: unable to publish outbox entry", entry.Id, err)
+	}
+	storeErr := this.store(entry)
+	if storeErr != nil {
+		log.Println("ERROR: unable to update outbox entry", entry.Id, storeErr)
+	}
+	this.entries[index] = entry
+	return 0
+}
+
+func (this *Outbox) Start() {
+	go func() {
+		for {
+			wait := this.processNext(time.Now())
+			switch {
+			case wait < 0:
+				<-this.signal
+			case wait > 0:
+				select {
+				case <-this.signal:
+				case <-time.After(wait):
+				}
+			}
+		}
+	}()
+}
+
+//correlationId has to match the correlation id of the event
+func NewOutboxMessage(topic string, correlationId string, event interface{}) (message OutboxMessage, err error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println("ERROR: event marshaling:", err)
+		return message, err
+	}
+	return OutboxMessage{Topic: topic, CorrelationId: correlationId, Payload: payload}, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func Example_outbox() {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	brokerDown := true
+	publish := func(topic string, payload []byte) error {
+		if brokerDown && topic == "instance" {
+			return errors.New("broker down")
+		}
+		fmt.Println("publish", topic, string(payload))
+		return nil
+	}
+	outbox, err := NewOutbox(dir, 2, time.Second, time.Minute, publish)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	outbox.Record(OutboxMessage{Topic: "type", Payload: []byte(`"a"`)}, OutboxMessage{Topic: "instance", Payload: []byte(`"b"`)})
+	outbox.Record(OutboxMessage{Topic: "type", Payload: []byte(`"c"`)})
+
+	now := time.Now()
+	fmt.Println(outbox.processNext(now))
+	fmt.Println(outbox.processNext(now)) //later entries wait for the first
+	entry := outbox.List(OutboxPending)[0]
+	fmt.Println(entry.Id, entry.Published, entry.Attempts, entry.LastError)
+	fmt.Println(outbox.processNext(now.Add(time.Second)))
+	fmt.Println(len(outbox.List(OutboxFailed)), len(outbox.List(OutboxPending)))
+
+	//reload from disk
+	brokerDown = false
+	outbox, err = NewOutbox(dir, 2, time.Second, time.Minute, publish)
+	fmt.Println(err, len(outbox.List("")))
+	fmt.Println(outbox.Retry("1"))
+	later := time.Now().Add(time.Minute)
+	fmt.Println(outbox.processNext(later))
+	fmt.Println(outbox.processNext(later))
+	fmt.Println(outbox.processNext(later), len(outbox.List("")))
+
+	_, err = NewOutbox("", 2, time.Second, time.Minute, publish)
+	fmt.Println(err)
+
+	// Output:
+	// publish type "a"
+	// 0s
+	// 1s
+	// 1 1 1 broker down
+	// 0s
+	// 1 1
+	// <nil> 2
+	// <nil>
+	// publish instance "b"
+	// 0s
+	// publish type "c"
+	// 0s
+	// -1ns 0
+	// missing outbox directory
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//publishes the changes from old to new as permission commands of the permissionsearch format to util.Config.PermissionsTopic
+//correlationId is empty if nothing changed
+func PublishPermissions(kind string, id string, old []model.Permission, new []model.Permission) (correlationId string, err error) {
+	if util.Config.PermissionsTopic == "" {
+		return "", errors.New("no permissions topic configured")
+	}
+	correlationId = NewCorrelationId()
+	commands := permission.PermissionCommands(kind, id, old, new, correlationId)
+	if len(commands) == 0 {
+		return "", nil
+	}
+	messages := []OutboxMessage{}
+	for _, command := range commands {
+		message, err := NewOutboxMessage(util.Config.PermissionsTopic, correlationId, command)
+		if err != nil {
+			return "", err
+		}
+		messages = append(messages, message)
+	}
+	return correlationId, PublishAll(messages...)
+}
+
+//applies permission commands to the auth entries of the embedded permission engine
+func getPermissionCommandHandler(db interfaces.Persistence) broker.ConsumerFunc {
+	return func(msg []byte) (err error) {
+		log.Println(util.Config.PermissionsTopic, string(msg))
+		command := permission.PermissionCommand{}
+		err = json.Unmarshal(msg, &command)
+		if err != nil {
+			return InvalidCommandError{Reason: err.Error()}
+		}
+		if command.Resource == "" {
+			return InvalidCommandError{Reason: "missing resource"}
+		}
+		auth, err := db.GetAuth(command.Resource)
+		if err != nil {
+			return err
+		}
+		if auth.Owner == "" {
+			//the resource may be created by a command of an other topic which is not yet consumed
+			return errors.New("no auth entry for resource " + command.Resource)
+		}
+		auth, err = permission.ApplyPermissionCommand(auth, command)
+		if err != nil {
+			return InvalidCommandError{Reason: err.Error()}
+		}
+		return db.SetAuth(auth)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	CommandPending = "pending"
+	CommandApplied = "applied"
+	CommandFailed  = "failed"
+)
+
+type CommandStatus struct {
+	Id       string    `json:"id"`
+	State    string    `json:"state"`
+	Error    string    `json:"error,omitempty"`
+	Messages int       `json:"messages"`
+	Applied  int       `json:"applied"`
+	Updated  time.Time `json:"updated"`
+	Owner    string    `json:"-"` //user who published the command; commands of other instances use the owner of the payload
+}
+
+type commandStatusEntry struct {
+	status  CommandStatus
+	changed chan bool //closed and replaced on each recorded result
+}
+
+//in memory results of the local consumers (and of other instances, see CommandStatusTopic) by correlation id
+type CommandStatusRegistry struct {
+	ttl         time.Duration
+	mux         sync.Mutex
+	entries     map[string]*commandStatusEntry
+	lastCleanup time.Time
+}
+
+func NewCommandStatusRegistry(ttl time.Duration) *CommandStatusRegistry {
+	return &CommandStatusRegistry{ttl: ttl, entries: map[string]*commandStatusEntry{}}
+}
+
+func NewCorrelationId() string {
+	return uuid.NewV4().String()
+}
+
+//removes entries not updated since ttl, at most once per ttl; expects locked mux
+func (this *CommandStatusRegistry) cleanup(now time.Time) {
+	if now.Sub(this.lastCleanup) <= this.ttl {
+		return
+	}
+	for id, entry := range this.entries {
+		if now.Sub(entry.status.Updated) > this.ttl {
+			delete(this.entries, id)
+		}
+	}
+	this.lastCleanup = now
+}
+
+func (this *CommandStatusRegistry) get(id string, now time.Time) *commandStatusEntry {
+	entry, ok := this.entries[id]
+	if !ok {
+		entry = &commandStatusEntry{status: CommandStatus{Id: id, State: CommandPending, Updated: now}, changed: make(chan bool)}
+		this.entries[id] = entry
+	}
+	return entry
+}
+
+//registers a command which is published with the given number of messages
+func (this *CommandStatusRegistry) Register(id string, messages int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	now := time.Now()
+	this.cleanup(now)
+	entry := this.get(id, now)
+	entry.status.Messages = entry.status.Messages + messages
+	entry.status.Updated = now
+}
+
+//sets the owner if the command has none yet
+func (this *CommandStatusRegistry) SetOwner(id string, owner string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry := this.get(id, time.Now())
+	if entry.status.Owner == "" {
+		entry.status.Owner = owner
+	}
+}
+
+func (this *CommandStatusRegistry) Forget(id string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.entries, id)
+}
+
+//records the result of one consumed message; failed messages are redelivered, so a later success may follow
+func (this *CommandStatusRegistry) Record(id string, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	now := time.Now()
+	//commands of other instances are recorded without Register()
+	this.cleanup(now)
+	entry := this.get(id, now)
+	entry.status.Updated = now
+	if entry.status.State == CommandApplied {
+		return
+	}
+	defer func() {
+		close(entry.changed)
+		entry.changed = make(chan bool)
+	}()
+	if err != nil {
+		entry.status.State = CommandFailed
+		entry.status.Error = err.Error()
+		return
+	}
+	entry.status.Applied++
+	//commands of other instances are not registered
+	if entry.status.Applied >= entry.status.Messages {
+		entry.status.State = CommandApplied
+		entry.status.Error = ""
+	}
+}
+
+func (this *CommandStatusRegistry) Get(id string) (status CommandStatus, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.entries[id]
+	if !ok {
+		return status, false
+	}
+	return entry.status, true
+}
+
+//waits until the command is applied or failed or the timeout is reached; returns the current status
+func (this *CommandStatusRegistry) Wait(id string, timeout time.Duration) (status CommandStatus, ok bool) {
+	deadline := time.After(timeout)
+	for {
+		this.mux.Lock()
+		entry, ok := this.entries[id]
+		if !ok {
+			this.mux.Unlock()
+			return status, false
+		}
+		status = entry.status
+		changed := entry.changed
+		this.mux.Unlock()
+		if status.State != CommandPending {
+			return status, true
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return status, true
+		}
+	}
+}
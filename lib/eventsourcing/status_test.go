@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventsourcing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+func Example_commandStatus() {
+	registry := NewCommandStatusRegistry(time.Minute)
+	registry.Register("a", 2)
+
+	status, ok := registry.Wait("a", 10*time.Millisecond)
+	fmt.Println(ok, status.State, status.Applied)
+
+	registry.Record("a", nil)
+	status, _ = registry.Get("a")
+	fmt.Println(status.State, status.Applied)
+
+	registry.Record("a", errors.New("db down"))
+	status, _ = registry.Wait("a", time.Second)
+	fmt.Println(status.State, status.Error)
+
+	//redelivered message
+	registry.Record("a", nil)
+	status, _ = registry.Get("a")
+	fmt.Println(status.State, status.Applied, status.Error == "")
+
+	registry.Register("b", 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		registry.Record("b", nil)
+	}()
+	status, _ = registry.Wait("b", time.Second)
+	fmt.Println(status.State)
+
+	_, ok = registry.Wait("unknown", time.Second)
+	fmt.Println(ok)
+
+	//the first owner is kept
+	registry.SetOwner("b", "user")
+	registry.SetOwner("b", "other")
+	status, _ = registry.Get("b")
+	fmt.Println(status.Owner)
+
+	//Output:
+	//true pending 0
+	//pending 1
+	//failed db down
+	//applied 2 true
+	//applied
+	//false
+	//user
+}
+
+func Example_commandStatusCleanup() {
+	registry := NewCommandStatusRegistry(10 * time.Millisecond)
+	//commands of other instances are only recorded
+	registry.Record("a", nil)
+	_, ok := registry.Get("a")
+	fmt.Println(ok)
+	time.Sleep(20 * time.Millisecond)
+	registry.Record("b", nil)
+	_, ok = registry.Get("a")
+	fmt.Println(ok)
+
+	//Output:
+	//true
+	//false
+}
@@ -17,44 +17,69 @@
 package eventsourcing
 
 import (
-	"encoding/json"
-	"errors"
 	"log"
 
-	"github.com/SmartEnergyPlatform/amqp-wrapper-lib"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/broker"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-type ValueTypeCommand struct {
-	Command   string          `json:"command"`
-	Id        string          `json:"id"`
-	Owner     string          `json:"owner"`
-	ValueType model.ValueType `json:"value_type"`
+//payload of PUT, DELETE, MERGE and RENAME commands of the value type topic
+type ValueTypePayload struct {
+	Id         string          `json:"id"`
+	Owner      string          `json:"owner"`
+	ValueType  model.ValueType `json:"value_type"`
+	Duplicates []string        `json:"duplicates,omitempty"`
 }
 
-func getValueTypeCommandHandler(db interfaces.Persistence) amqp_wrapper_lib.ConsumerFunc {
+func (this ValueTypePayload) Validate(commandType string) error {
+	switch commandType {
+	case CommandPut:
+		if this.ValueType.Id == "" {
+			return missingField("value_type.id")
+		}
+	case CommandDelete:
+	case CommandMerge:
+		if len(this.Duplicates) == 0 {
+			return missingField("duplicates")
+		}
+	case CommandRename:
+		if this.ValueType.Name == "" {
+			return missingField("value_type.name")
+		}
+	default:
+		return unknownCommandType(commandType)
+	}
+	if this.Id == "" {
+		return missingField("id")
+	}
+	return nil
+}
+
+func getValueTypeCommandHandler(db interfaces.Persistence) broker.ConsumerFunc {
 	return func(msg []byte) (err error) {
 		log.Println(util.Config.ValueTypeTopic, string(msg))
-		command := ValueTypeCommand{}
-		err = json.Unmarshal(msg, &command)
+		payload := ValueTypePayload{}
+		command, err := ParseCommand(msg, &payload)
 		if err != nil {
-			return
+			return err
 		}
-		switch command.Command {
-		case "PUT":
-			return recursiveValueTypeCreation(db, command.ValueType, command.Owner)
-		case "DELETE":
-			return db.DeleteValueType(command.Id)
+		switch command.Type {
+		case CommandPut:
+			return recursiveValueTypeCreation(db, payload.ValueType, payload.Owner)
+		case CommandMerge:
+			return db.MergeValueTypes(payload.Id, payload.Duplicates)
+		case CommandRename:
+			return db.RenameValueType(payload.Id, payload.ValueType.Name)
 		}
-		return errors.New("unable to handle permission command: " + string(msg))
+		return db.DeleteValueType(payload.Id)
 	}
 }
 
 func recursiveValueTypeCreation(db interfaces.Persistence, vt model.ValueType, owner string) (err error) {
 	for _, field := range vt.Fields {
-		err = PublishValueType(field.Type, owner)
+		_, err = PublishValueType(field.Type, owner)
 		if err != nil {
 			return err
 		}
@@ -62,14 +87,29 @@ func recursiveValueTypeCreation(db interfaces.Persistence, vt model.ValueType, o
 	return db.CreateValueType(vt)
 }
 
-func PublishValueType(vt model.ValueType, owner string) (err error) {
+//correlationId is empty if nothing is published
+func PublishValueType(vt model.ValueType, owner string) (correlationId string, err error) {
 	if vt.Id == "" {
 		log.Println("WARNING: missing id in valuetype --> no publish")
-		return nil
+		return "", nil
 	}
-	return sendEvent(util.Config.ValueTypeTopic, ValueTypeCommand{ValueType: vt, Id: vt.Id, Command: "PUT", Owner: owner})
+	return publishValueTypeCommand(CommandPut, ValueTypePayload{ValueType: vt, Id: vt.Id, Owner: owner})
+}
+
+func PublishValueTypeRemove(id string) (correlationId string, err error) {
+	return publishValueTypeCommand(CommandDelete, ValueTypePayload{Id: id})
+}
+
+//replaces references to the duplicates with references to the canonical value type (id)
+func PublishValueTypeMerge(canonical string, duplicates []string) (correlationId string, err error) {
+	return publishValueTypeCommand(CommandMerge, ValueTypePayload{Id: canonical, Duplicates: duplicates})
+}
+
+func PublishValueTypeRename(id string, name string) (correlationId string, err error) {
+	return publishValueTypeCommand(CommandRename, ValueTypePayload{Id: id, ValueType: model.ValueType{Id: id, Name: name}})
 }
 
-func PublishValueTypeRemove(id string) (err error) {
-	return sendEvent(util.Config.ValueTypeTopic, ValueTypeCommand{Id: id, Command: "DELETE"})
+func publishValueTypeCommand(commandType string, payload ValueTypePayload) (correlationId string, err error) {
+	correlationId = NewCorrelationId()
+	return correlationId, sendCommand(util.Config.ValueTypeTopic, commandType, correlationId, payload)
 }
@@ -57,6 +57,10 @@ func GetBpmnSkeletonFromDeviceType(db interfaces.Persistence, deviceTypeId strin
 	allowedValues := model.GetAllowedValuesBase()
 
 	for _, input := range service.Input {
+		input.Type, err = db.ResolveValueTypeInheritance(input.Type)
+		if err != nil {
+			return result, err
+		}
 		input.Type, _ = removeLiteral(input.Type)
 		inputSkeleton, err := SkeletonFromAssignment(input, allowedValues)
 		if err != nil {
@@ -70,6 +74,10 @@ func GetBpmnSkeletonFromDeviceType(db interfaces.Persistence, deviceTypeId strin
 		result.Inputs[input.Name] = inputJson
 	}
 	for _, output := range service.Output {
+		output.Type, err = db.ResolveValueTypeInheritance(output.Type)
+		if err != nil {
+			return result, err
+		}
 		outputSkeleton, err := SkeletonFromAssignment(output, allowedValues)
 		if err != nil {
 			return result, err
@@ -75,8 +75,14 @@ func (info XmlInfo) getParts() (attr []xml.Attr, children []XmlInfo, err error)
 //TODO chardata
 func (this XmlInfo) MarshalXML(e *xml.Encoder, start xml.StartElement) (err error) {
 	_, anonym := getFieldInfo(this.Value.FieldId, this.fieldFlags)[XmlAnonym]
-	if anonym {
-		e.EncodeToken(xml.CharData(this.Value.Value))
+	if anonym && len(this.Value.Values) > 0 {
+		//anonymous lists: elements without wrapping element
+		for _, element := range this.Value.Values {
+			child := XmlInfo{AdditionalInfo: this.AdditionalInfo, Config: this.Config, fieldFlags: this.fieldFlags, Value: element}
+			e.EncodeElement(child, xml.StartElement{Name: xml.Name{Local: element.Name, Space: ""}})
+		}
+	} else if anonym {
+		e.EncodeToken(xml.CharData(UseDeviceConfig(this.Config, this.Value.Value)))
 	} else {
 		start.Name = xml.Name{Local: this.Value.Name, Space: ""}
 		attr, childElements, err := this.getParts()
@@ -102,8 +108,18 @@ func ParseFromXml(valueType model.ValueType, value string, infos []model.Additio
 	if err != nil {
 		return result, err
 	}
+	root := InputOutput{}
+	for _, element := range preparedIO {
+		if element.Name != "" {
+			root = element
+			break
+		}
+	}
+	if root.Name == "" {
+		return result, errors.New("missing xml root element")
+	}
 	xmlInfo := XmlInfo{AdditionalInfo: infos}.Init()
-	return finishIo(model.FieldType{Type: valueType, Name: preparedIO[0].Name}, preparedIO, xmlInfo.fieldFlags)
+	return finishIo(model.FieldType{Type: valueType, Name: root.Name}, preparedIO, xmlInfo.fieldFlags)
 }
 
 func prepareInputOutput(decoder *xml.Decoder) (result []InputOutput, err error) {
@@ -121,6 +137,7 @@ func prepareInputOutput(decoder *xml.Decoder) (result []InputOutput, err error)
 			result = append(result, InputOutput{Value: string(element)})
 		case xml.EndElement:
 			return
+		case xml.ProcInst, xml.Comment, xml.Directive:
 		default:
 			return result, errors.New("unknown xml token type: " + reflect.TypeOf(element).Name())
 		}
@@ -162,6 +179,8 @@ func finishIo(field model.FieldType, prepared []InputOutput, info map[string]map
 
 	switch {
 	case allowedValues.IsPrimitive(field.Type):
+		//attributes are prepared with value
+		result.Value = thisValue.Value
 		textParts := getAllMatchingInputOutput("", thisValue.Values)
 		for _, part := range textParts {
 			result.Value += part.Value
@@ -183,11 +202,12 @@ func finishIo(field model.FieldType, prepared []InputOutput, info map[string]map
 	default:
 		for _, subField := range field.Type.Fields {
 			_, anonym := getFieldInfo(subField.Id, info)[XmlAnonym]
-			preparedIoSet := childValues
+			preparedIoSet := getAllMatchingInputOutput(subField.Name, childValues)
 			if anonym {
-				preparedIoSet = prepared
+				//content of anonymous fields (text, list elements) is part of this element
+				preparedIoSet = []InputOutput{{Name: subField.Name, Values: childValues}}
 			}
-			for _, preparedField := range getAllMatchingInputOutput(subField.Name, preparedIoSet) {
+			for _, preparedField := range preparedIoSet {
 				subValue, err := finishIo(subField, []InputOutput{preparedField}, info)
 				if err != nil {
 					return result, err
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func xmlTestField(id string, name string, baseType string, fields ...model.FieldType) model.FieldType {
+	return model.FieldType{Id: id, Name: name, Type: model.ValueType{Id: id + ".type", Name: name, BaseType: baseType, Fields: fields}}
+}
+
+func xmlTestInfos(flags map[string]string) (result []model.AdditionalFormatInfo) {
+	for _, id := range []string{"id", "temperature.unit", "temperature.text", "value", "sample", "sample.at", "sample.text"} {
+		if flag, ok := flags[id]; ok {
+			result = append(result, model.AdditionalFormatInfo{Field: model.FieldType{Id: id}, FormatFlag: flag})
+		}
+	}
+	return
+}
+
+func printXmlValues(io InputOutput, indent string) {
+	fmt.Println(indent + "'" + io.Name + "' " + io.FieldId + " '" + io.Value + "'")
+	for _, value := range io.Values {
+		printXmlValues(value, indent+"  ")
+	}
+}
+
+func Example_xmlAnonymousFieldsRoundTrip() {
+	valueType := xmlTestField("sensor", "sensor", model.StructBaseType,
+		xmlTestField("id", "id", model.XsdString),
+		xmlTestField("temperature", "temperature", model.StructBaseType,
+			xmlTestField("temperature.unit", "unit", model.XsdString),
+			xmlTestField("temperature.text", "#text", model.XsdFloat),
+		),
+		xmlTestField("value", "value", model.ListBaseType,
+			xmlTestField("value.item", "value", model.XsdInt),
+		),
+		xmlTestField("sample", "sample", model.ListBaseType,
+			xmlTestField("sample.item", "sample", model.StructBaseType,
+				xmlTestField("sample.at", "at", model.XsdString),
+				xmlTestField("sample.text", "#text", model.XsdString),
+			),
+		),
+	).Type
+	infos := xmlTestInfos(map[string]string{
+		"id":               XmlAttrFlag,
+		"temperature.unit": XmlAttrFlag,
+		"temperature.text": XmlAnonym,
+		"value":            XmlAnonym,
+		"sample":           XmlAnonym,
+		"sample.at":        XmlAttrFlag,
+		"sample.text":      XmlAnonym,
+	})
+	msg := `<?xml version="1.0"?>
+<!-- list elements are not wrapped -->
+<sensor id="s1">
+    <temperature unit="C">21.5</temperature>
+    <value>1</value>
+    <sample at="t1">a</sample>
+    <value>2</value>
+    <sample at="t2">b</sample>
+</sensor>`
+
+	parsed, err := ParseFromXml(valueType, msg, infos)
+	fmt.Println(err)
+	printXmlValues(parsed, "")
+
+	result, err := FormatToXml(nil, parsed, infos)
+	fmt.Println(result, err)
+
+	reparsed, err := ParseFromXml(valueType, result, infos)
+	fmt.Println(err)
+	again, err := FormatToXml(nil, reparsed, infos)
+	fmt.Println(again == result, err)
+
+	//empty anonymous lists are omitted; anonymous text uses the device config
+	msg = `<sensor id="s2"><temperature unit="K">{{temp}}</temperature></sensor>`
+	parsed, err = ParseFromXml(valueType, msg, infos)
+	fmt.Println(err, len(parsed.Values[2].Values), len(parsed.Values[3].Values))
+	result, err = FormatToXml([]model.ConfigField{{Name: "temp", Value: "300"}}, parsed, infos)
+	fmt.Println(result, err)
+
+	// Output:
+	// <nil>
+	// 'sensor'  ''
+	//   'id' id 's1'
+	//   'temperature' temperature ''
+	//     'unit' temperature.unit 'C'
+	//     '#text' temperature.text '21.5'
+	//   'value' value ''
+	//     'value' value.item '1'
+	//     'value' value.item '2'
+	//   'sample' sample ''
+	//     'sample' sample.item ''
+	//       'at' sample.at 't1'
+	//       '#text' sample.text 'a'
+	//     'sample' sample.item ''
+	//       'at' sample.at 't2'
+	//       '#text' sample.text 'b'
+	// <sensor id="s1">
+	//     <temperature unit="C">21.5</temperature>
+	//     <value>1</value>
+	//     <value>2</value>
+	//     <sample at="t1">a</sample>
+	//     <sample at="t2">b</sample>
+	// </sensor> <nil>
+	// <nil>
+	// true <nil>
+	// <nil> 0 0
+	// <sensor id="s2">
+	//     <temperature unit="K">300</temperature>
+	// </sensor> <nil>
+}
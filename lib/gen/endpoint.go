@@ -19,6 +19,7 @@ package gen
 import (
 	"encoding/json"
 	"errors"
+	"log"
 
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
@@ -26,35 +27,47 @@ import (
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
-func CreateNewEndpoint(db interfaces.Persistence, endpointMsg EndpointGenMsg, owner string) (endpoint model.Endpoint, err error) {
+//device type and instance share the returned correlation id
+func CreateNewEndpoint(db interfaces.Persistence, endpointMsg EndpointGenMsg, owner string) (endpoint model.Endpoint, correlationId string, err error) {
 	protocol, err := db.GetProtocolByUri(endpointMsg.ProtocolHandler)
 	if err != nil {
-		return endpoint, err
+		return endpoint, correlationId, err
 	}
 	dt, err := generateDeviceType(db, protocol, endpointMsg)
+	if err != nil {
+		return endpoint, correlationId, err
+	}
+	//device type and instance are published together to prevent orphans
+	correlationId = eventsourcing.NewCorrelationId()
+	messages := []eventsourcing.OutboxMessage{}
 	if dt.Id == "" {
 		err = db.SetId(&dt)
 		if err != nil {
-			return endpoint, err
+			return endpoint, correlationId, err
 		}
-		err = eventsourcing.PublishDeviceType(dt, owner)
+		message, err := eventsourcing.DeviceTypeMessage(dt, owner, correlationId)
 		if err != nil {
-			return endpoint, err
+			return endpoint, correlationId, err
 		}
+		messages = append(messages, message)
 	}
 	instance := model.DeviceInstance{
 		DeviceType: dt.Id,
 		Url:        endpointMsg.Endpoint,
-		Tags:       []string{"generated:generated"},
+		Tags:       []string{model.GeneratedDeviceInstanceTag},
 		Name:       endpointMsg.Endpoint,
 	}
 	err = db.SetId(&instance)
 	if err != nil {
-		return endpoint, err
+		return endpoint, correlationId, err
 	}
-	err = eventsourcing.PublishDeviceInstance(instance, owner)
+	message, err := eventsourcing.DeviceInstanceMessage(instance, owner, correlationId)
 	if err != nil {
-		return endpoint, err
+		return endpoint, correlationId, err
+	}
+	err = eventsourcing.PublishAll(append(messages, message)...)
+	if err != nil {
+		return endpoint, correlationId, err
 	}
 	endpoint = model.Endpoint{Device: instance.Id, Endpoint: endpointMsg.Endpoint, ProtocolHandler: protocol.ProtocolHandlerUrl, Service: dt.Services[0].Id}
 	return
@@ -63,12 +76,21 @@ func CreateNewEndpoint(db interfaces.Persistence, endpointMsg EndpointGenMsg, ow
 func generateDeviceType(db interfaces.Persistence, protocol model.Protocol, endpointMsg EndpointGenMsg) (result model.DeviceType, err error) {
 	msgDesc := map[string]interface{}{}
 	outputs := []model.TypeAssignment{}
+	inferredOutputs := []*inferredType{}
 	for _, part := range endpointMsg.Parts {
-		vt, format, struc, err := ValueTypeFromMessage(db, part.Msg)
+		name := protocol.ProtocolHandlerUrl + "_" + endpointMsg.Endpoint + "." + part.MsgSegmentName
+		inferred, format, strucs, conflicts, err := inferFromMessages(part.Samples(), name)
+		if err != nil {
+			return result, err
+		}
+		vt, err := inferredToValueType(db, inferred, name)
 		if err != nil {
 			return result, err
 		}
-		msgDesc[part.MsgSegmentName] = struc
+		for _, conflict := range conflicts {
+			log.Println("WARNING: conflicting sample types for", endpointMsg.Endpoint, conflict)
+		}
+		msgDesc[part.MsgSegmentName] = strucs[0]
 		if vt.Id == "" {
 			vt.Description = "generated valuetype"
 		}
@@ -90,6 +112,7 @@ func generateDeviceType(db interfaces.Persistence, protocol model.Protocol, endp
 				Id: msgSegmentId,
 			},
 		})
+		inferredOutputs = append(inferredOutputs, inferred)
 	}
 
 	result.Services = []model.Service{{
@@ -98,7 +121,7 @@ func generateDeviceType(db interfaces.Persistence, protocol model.Protocol, endp
 		EndpointFormat: "{{device_uri}}",
 		Url:            "get",
 		Protocol:       model.Protocol{Id: protocol.Id},
-		ServiceType:    "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Sensor",
+		ServiceType:    model.SensorServiceType,
 		Output:         outputs,
 	}}
 	result.Vendor = model.Vendor{Id: util.Config.GeneratVendor}
@@ -115,15 +138,24 @@ func generateDeviceType(db interfaces.Persistence, protocol model.Protocol, endp
 		msgDescStr, _ := json.Marshal(msgDesc)
 		result.Name = protocol.ProtocolHandlerUrl + "_" + endpointMsg.Endpoint
 		result.Description = "generated devicetype for " + protocol.Name + "\n" + string(msgDescStr)
-		result.Maintenance = []string{"rename"}
+		result.Maintenance = []string{model.MaintenanceRename}
+		err = setXmlFormatInfo(db, result.Services[0].Output, inferredOutputs)
 	}
 
 	return
 }
 
 type EndpointGenMsgPart struct {
-	Msg            string `json:"msg"`
-	MsgSegmentName string `json:"msg_segment_name"`
+	Msg            string   `json:"msg"`
+	MsgSamples     []string `json:"msg_samples,omitempty"` //additional messages to infer the value type from
+	MsgSegmentName string   `json:"msg_segment_name"`
+}
+
+func (this EndpointGenMsgPart) Samples() (result []string) {
+	if this.Msg != "" {
+		result = append(result, this.Msg)
+	}
+	return append(result, this.MsgSamples...)
 }
 
 type EndpointGenMsg struct {
@@ -131,3 +163,21 @@ type EndpointGenMsg struct {
 	ProtocolHandler string               `json:"protocol_handler"`
 	Parts           []EndpointGenMsgPart `json:"parts"`
 }
+
+//xml attributes and text content need format flags referencing the ids of their fields
+func setXmlFormatInfo(db interfaces.Persistence, outputs []model.TypeAssignment, inferred []*inferredType) (err error) {
+	for index, output := range outputs {
+		if output.Format != XML_FORMAT {
+			continue
+		}
+		err = db.SetId(&outputs[index].Type)
+		if err != nil {
+			return err
+		}
+		outputs[index].AdditionalFormatinfo, err = xmlFormatInfo(db, outputs[index].Type, inferred[index])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gen
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+type inferredKind string
+
+const (
+	inferredString inferredKind = "string"
+	inferredInt    inferredKind = "integer"
+	inferredFloat  inferredKind = "float"
+	inferredBool   inferredKind = "boolean"
+	inferredStruct inferredKind = "structure"
+	inferredList   inferredKind = "list"
+)
+
+//structure of one or more merged sample values
+type inferredType struct {
+	Kind        inferredKind
+	Samples     int                       //number of merged structure samples
+	Fields      map[string]*inferredField //structure fields by key
+	Element     *inferredType             //list element; nil if no list contained elements
+	ElementName string                    //name of repeated xml elements
+}
+
+type inferredField struct {
+	Type  *inferredType
+	Count int      //number of structure samples containing the field
+	Flags []string //format flags (e.g. xml attr)
+}
+
+//collects conflicts while inferring and merging sample values
+type typeInference struct {
+	Conflicts []string
+	known     map[string]bool
+}
+
+func (this *typeInference) addConflict(path string, a inferredKind, b inferredKind) {
+	conflict := path + ": " + string(a) + " conflicts with " + string(b)
+	if this.known == nil {
+		this.known = map[string]bool{}
+	}
+	if !this.known[conflict] {
+		this.known[conflict] = true
+		this.Conflicts = append(this.Conflicts, conflict)
+	}
+}
+
+//returns nil for null values
+func (this *typeInference) infer(value interface{}, path string) (result *inferredType, err error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &inferredType{Kind: inferredInt}, nil
+		}
+		return &inferredType{Kind: inferredFloat}, nil
+	case string:
+		return &inferredType{Kind: inferredString}, nil
+	case bool:
+		return &inferredType{Kind: inferredBool}, nil
+	case float64:
+		return &inferredType{Kind: inferredFloat}, nil
+	case int64, int:
+		return &inferredType{Kind: inferredInt}, nil
+	case xmlNode:
+		return this.inferXml(v, path), nil
+	case map[string]interface{}:
+		result = &inferredType{Kind: inferredStruct, Samples: 1, Fields: map[string]*inferredField{}}
+		for key, val := range v {
+			fieldType, err := this.infer(val, path+"."+key)
+			if err != nil {
+				return result, err
+			}
+			if fieldType != nil {
+				result.Fields[key] = &inferredField{Type: fieldType, Count: 1}
+			}
+		}
+		return result, nil
+	case []interface{}:
+		result = &inferredType{Kind: inferredList}
+		for _, element := range v {
+			elementType, err := this.infer(element, path+"[]")
+			if err != nil {
+				return result, err
+			}
+			result.Element = this.merge(result.Element, elementType, path+"[]")
+		}
+		return result, nil
+	default:
+		return nil, errors.New("unknown kind of value: " + reflect.ValueOf(value).Kind().String())
+	}
+}
+
+//merges b into a; integers are widened to floats, on conflicts a wins
+func (this *typeInference) merge(a *inferredType, b *inferredType, path string) *inferredType {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.Kind != b.Kind {
+		//single xml elements are elements of lists of repeated elements in other samples
+		if a.Kind == inferredList && a.ElementName != "" {
+			a.Element = this.merge(a.Element, b, path+"[]")
+			return a
+		}
+		if b.Kind == inferredList && b.ElementName != "" {
+			b.Element = this.merge(a, b.Element, path+"[]")
+			return b
+		}
+		if (a.Kind == inferredInt || a.Kind == inferredFloat) && (b.Kind == inferredInt || b.Kind == inferredFloat) {
+			return &inferredType{Kind: inferredFloat}
+		}
+		this.addConflict(path, a.Kind, b.Kind)
+		return a
+	}
+	switch a.Kind {
+	case inferredStruct:
+		a.Samples = a.Samples + b.Samples
+		for key, field := range b.Fields {
+			if existing, ok := a.Fields[key]; ok {
+				existing.Type = this.merge(existing.Type, field.Type, path+"."+key)
+				existing.Count = existing.Count + field.Count
+				existing.Flags = mergeFlags(existing.Flags, field.Flags)
+			} else {
+				a.Fields[key] = field
+			}
+		}
+	case inferredList:
+		a.Element = this.merge(a.Element, b.Element, path+"[]")
+		if a.ElementName == "" {
+			a.ElementName = b.ElementName
+		}
+	}
+	return a
+}
+
+func mergeFlags(a []string, b []string) []string {
+	for _, flag := range b {
+		if !contains(a, flag) {
+			a = append(a, flag)
+		}
+	}
+	return a
+}
+
+func contains(list []string, element string) bool {
+	for _, e := range list {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
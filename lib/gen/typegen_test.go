@@ -20,15 +20,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"time"
 
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/format"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 
 	"sort"
 
 	"strings"
-
-	"github.com/bouk/monkey"
 )
 
 func ExampleFormatCheck() {
@@ -40,7 +39,9 @@ func ExampleFormatCheck() {
 }`
 	format, struca := getFormatStruct(jsonval)
 	var strucb interface{}
-	err := json.Unmarshal([]byte(jsonval), &strucb)
+	decoder := json.NewDecoder(strings.NewReader(jsonval))
+	decoder.UseNumber()
+	err := decoder.Decode(&strucb)
 	fmt.Println(format == JSON_FORMAT, err, reflect.DeepEqual(strucb, struca))
 
 	// Output:
@@ -50,7 +51,18 @@ func ExampleFormatCheck() {
 type dbMockCheck func(model.ValueType) (bool, string, error)
 
 type DbMock struct {
+	interfaces.Persistence
 	ValueTypeQueryMock dbMockCheck
+	ExistingNames      []string
+}
+
+func (this DbMock) ValueTypeNameExists(name string) (exists bool, err error) {
+	for _, existing := range this.ExistingNames {
+		if existing == name {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (this DbMock) ValueTypeQuery(valueType model.ValueType) (exists bool, id string, err error) {
@@ -68,56 +80,133 @@ func (a ByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByName) Less(i, j int) bool { return strings.Compare(a[i].Name, a[j].Name) < 0 }
 
 func ExampleGenerateValueType() {
-	count = 0
-
-	//set time: ultra dirty
-	wayback := time.Date(1974, time.May, 19, 1, 2, 3, 4, time.UTC)
-	patch := monkey.Patch(time.Now, func() time.Time { return wayback })
-	defer patch.Unpatch()
-
 	jsonval := `{
 	"a": "a",
 	"b": 1,
 	"c": [1,2,3],
     "d": ["a", "b", "c"]
 }`
-	_, struc := getFormatStruct(jsonval)
-	valueType, err := interfaceToValueType(DbMock{}, struc)
+	valueType, _, _, _, err := ValueTypeFromMessages(DbMock{}, []string{jsonval}, "mqtt_sensor1.payload")
 	sort.Sort(ByName(valueType.Fields))
 	vtJson, err2 := json.Marshal(valueType)
 	fmt.Println(string(vtJson), err, err2)
 
 	// Output:
-	//{"name":"138157323_3","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"a","type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}},{"name":"b","type":{"id":"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0","fields":null,"literal":""}},{"name":"c","type":{"name":"138157323_1","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0","fields":null,"literal":""}}],"literal":""}},{"name":"d","type":{"name":"138157323_2","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}}],"literal":""}}],"literal":""} <nil> <nil>
+	//{"name":"mqtt_sensor1.payload","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"a","type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}},{"name":"b","type":{"id":"iot#01190060-db2e-4ed0-a424-c82b60f981e4","fields":null,"literal":""}},{"name":"c","type":{"name":"mqtt_sensor1.payload.c","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#01190060-db2e-4ed0-a424-c82b60f981e4","fields":null,"literal":""}}],"literal":""}},{"name":"d","type":{"name":"mqtt_sensor1.payload.d","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}}],"literal":""}}],"literal":""} <nil> <nil>
 }
 
 func ExampleGenerateValueType2() {
-	count = 0
-
-	//set time: ultra dirty
-	wayback := time.Date(1974, time.May, 19, 1, 2, 3, 4, time.UTC)
-	patch := monkey.Patch(time.Now, func() time.Time { return wayback })
-	defer patch.Unpatch()
-
 	jsonval := `{
 	"a": "a",
 	"b": 1,
 	"c": [1,2,3],
     "d": ["a", "b", "c"]
 }`
-	_, struc := getFormatStruct(jsonval)
-	valueType, err := interfaceToValueType(DbMock{
+	valueType, _, _, _, err := ValueTypeFromMessages(DbMock{
 		ValueTypeQueryMock: func(valueType model.ValueType) (bool, string, error) {
 			if valueType.BaseType == model.ListBaseType {
 				return true, "thisismyid", nil
 			}
 			return false, "", nil
 		},
-	}, struc)
+	}, []string{jsonval}, "")
 	sort.Sort(ByName(valueType.Fields))
 	vtJson, err2 := json.Marshal(valueType)
 	fmt.Println(string(vtJson), err, err2)
 
 	// Output:
-	//{"name":"138157323_1","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"a","type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}},{"name":"b","type":{"id":"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0","fields":null,"literal":""}},{"name":"c","type":{"id":"thisismyid","fields":null,"literal":""}},{"name":"d","type":{"id":"thisismyid","fields":null,"literal":""}}],"literal":""} <nil> <nil>
+	//{"name":"generated","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"a","type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}},{"name":"b","type":{"id":"iot#01190060-db2e-4ed0-a424-c82b60f981e4","fields":null,"literal":""}},{"name":"c","type":{"id":"thisismyid","fields":null,"literal":""}},{"name":"d","type":{"id":"thisismyid","fields":null,"literal":""}}],"literal":""} <nil> <nil>
+}
+
+func Example_generateValueTypeName() {
+	db := DbMock{ExistingNames: []string{"mqtt_sensor1.payload.temperature", "mqtt_sensor1.payload.temperature_2"}}
+	fmt.Println(generateValueTypeName(db, "mqtt_sensor1.payload.temperature"))
+	fmt.Println(generateValueTypeName(db, "mqtt_sensor1.payload.humidity"))
+	fmt.Println(generateValueTypeName(db, ""))
+
+	// Output:
+	// mqtt_sensor1.payload.temperature_3 <nil>
+	// mqtt_sensor1.payload.humidity <nil>
+	// generated <nil>
+}
+
+func Example_generateValueTypeFromSamples() {
+	samples := []string{
+		`{"temperature": 21, "unit": "C", "values": [1, 2], "tags": ["a", 1]}`,
+		`{"temperature": 21.5, "values": [{"v": 1}], "status": null}`,
+		`{"temperature": 22, "unit": "C", "values": [3.5], "status": {"ok": true}}`,
+	}
+	valueType, format, _, conflicts, err := ValueTypeFromMessages(DbMock{}, samples, "mqtt_sensor1.payload")
+	vtJson, err2 := json.Marshal(valueType)
+	fmt.Println(format == JSON_FORMAT, err, err2)
+	fmt.Println(conflicts)
+	fmt.Println(string(vtJson))
+
+	// Output:
+	// true <nil> <nil>
+	// [mqtt_sensor1.payload.tags[]: string conflicts with integer mqtt_sensor1.payload.values[]: integer conflicts with structure]
+	//{"name":"mqtt_sensor1.payload","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"status","type":{"name":"mqtt_sensor1.payload.status","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure","fields":[{"name":"ok","type":{"id":"iot#939963e5-1ab0-44e0-8fb4-5235fd6f5363","fields":null,"literal":""}}],"literal":""},"optional":true},{"name":"tags","type":{"name":"mqtt_sensor1.payload.tags","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""}}],"literal":""},"optional":true},{"name":"temperature","type":{"id":"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0","fields":null,"literal":""}},{"name":"unit","type":{"id":"iot#c8c36810-c8e0-403e-b00f-187414a84ccd","fields":null,"literal":""},"optional":true},{"name":"values","type":{"name":"mqtt_sensor1.payload.values","description":"generated","base_type":"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list","fields":[{"type":{"id":"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0","fields":null,"literal":""}}],"literal":""}}],"literal":""}
+}
+
+var primitiveBaseTypes = map[string]string{
+	"iot#01190060-db2e-4ed0-a424-c82b60f981e4": model.XsdInt,
+	"iot#c8c36810-c8e0-403e-b00f-187414a84ccd": model.XsdString,
+	"iot#939963e5-1ab0-44e0-8fb4-5235fd6f5363": model.XsdBool,
+	"iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0": model.XsdFloat,
+}
+
+//simulates SetId and loading of primitive value types
+func setFieldIds(valueType *model.ValueType, prefix string) {
+	if baseType, ok := primitiveBaseTypes[valueType.Id]; ok {
+		valueType.BaseType = baseType
+	}
+	for index := range valueType.Fields {
+		valueType.Fields[index].Id = prefix + "." + valueType.Fields[index].Name
+		setFieldIds(&valueType.Fields[index].Type, valueType.Fields[index].Id)
+	}
+}
+
+func Example_generateValueTypeFromXml() {
+	msg := `<?xml version="1.0"?>
+<sensor id="s1">
+	<temperature unit="C">21.5</temperature>
+	<value>1</value>
+	<value>2</value>
+	<on>true</on>
+</sensor>`
+	inferred, msgFormat, _, conflicts, err := inferFromMessages([]string{msg}, "mqtt_sensor1.payload")
+	fmt.Println(msgFormat == XML_FORMAT, conflicts, err)
+	valueType, err := inferredToValueType(DbMock{}, inferred, "mqtt_sensor1.payload")
+	setFieldIds(&valueType, "f")
+	infos, err2 := xmlFormatInfo(DbMock{}, valueType, inferred)
+	fmt.Println(err, err2)
+	for _, field := range valueType.Fields {
+		fmt.Println(field.Name, field.Type.BaseType, field.Type.Id+field.Type.Name)
+	}
+	for _, info := range infos {
+		fmt.Println(info.Field.Id, info.FormatFlag)
+	}
+	parsed, err := format.ParseFromXml(valueType, msg, infos)
+	fmt.Println(err)
+	result, err := format.FormatToXml(nil, parsed, infos)
+	fmt.Println(result, err)
+
+	// Output:
+	// true [] <nil>
+	// <nil> <nil>
+	// id http://www.w3.org/2001/XMLSchema#string iot#c8c36810-c8e0-403e-b00f-187414a84ccd
+	// on http://www.w3.org/2001/XMLSchema#boolean iot#939963e5-1ab0-44e0-8fb4-5235fd6f5363
+	// temperature http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure mqtt_sensor1.payload.temperature
+	// value http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#list mqtt_sensor1.payload.value
+	// f.id attr
+	// f.temperature.#text anonym
+	// f.temperature.unit attr
+	// f.value anonym
+	// <nil>
+	// <sensor id="s1">
+	//     <on>true</on>
+	//     <temperature unit="C">21.5</temperature>
+	//     <value>1</value>
+	//     <value>2</value>
+	// </sensor> <nil>
 }
@@ -19,15 +19,14 @@ package gen
 import (
 	"encoding/json"
 	"errors"
-	"reflect"
+	"sort"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 
 	"log"
 
-	"github.com/knakk/rdf"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
 )
 
@@ -37,12 +36,25 @@ const (
 	UNKNOWN_FORMAT Format = ""
 	JSON_FORMAT           = "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#json"
 	PLAIN_FORMAT          = "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#PlainText"
+	XML_FORMAT            = "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#xml"
 )
 
+//numbers are kept as json.Number to distinguish integers from floats
 func getFormatStruct(value string) (format Format, structure interface{}) {
-	err := json.Unmarshal([]byte(value), &structure)
+	if json.Valid([]byte(value)) {
+		decoder := json.NewDecoder(strings.NewReader(value))
+		decoder.UseNumber()
+		err := decoder.Decode(&structure)
+		if err == nil {
+			format = JSON_FORMAT
+			return
+		}
+	}
+
+	node, err := parseXml(value)
 	if err == nil {
-		format = JSON_FORMAT
+		structure = node
+		format = XML_FORMAT
 		return
 	}
 
@@ -51,63 +63,72 @@ func getFormatStruct(value string) (format Format, structure interface{}) {
 	return
 }
 
-func interfaceToValueType(db interfaces.Persistence, value interface{}) (result model.ValueType, err error) {
-	v := reflect.ValueOf(value)
-	switch v.Kind() {
-	case reflect.Slice:
-		result, err = sliceToValueType(db, v)
-	case reflect.Map:
-		result, err = mapToValueType(db, v)
-	case reflect.Int64:
+//path is used to name new value types (e.g. "mqtt_sensor1.payload.temperature")
+func inferredToValueType(db interfaces.Persistence, inferred *inferredType, path string) (result model.ValueType, err error) {
+	if inferred == nil {
+		return
+	}
+	switch inferred.Kind {
+	case inferredList:
+		result, err = listToValueType(db, inferred, path)
+	case inferredStruct:
+		result, err = structToValueType(db, inferred, path)
+	case inferredInt:
 		result.Id = "iot#01190060-db2e-4ed0-a424-c82b60f981e4"
-	case reflect.String:
+	case inferredString:
 		result.Id = "iot#c8c36810-c8e0-403e-b00f-187414a84ccd"
-	case reflect.Bool:
+	case inferredBool:
 		result.Id = "iot#939963e5-1ab0-44e0-8fb4-5235fd6f5363"
-	case reflect.Float64:
+	case inferredFloat:
 		result.Id = "iot#cb0dc896-6d89-4e0c-ac59-33eceed512b0"
 	default:
-		err = errors.New("unknown kind of value: " + v.Kind().String())
+		err = errors.New("unknown kind of value: " + string(inferred.Kind))
 	}
-	if result.Id == "" {
-		result.Name = generateValueTypeName(result)
+	if err == nil && result.Id == "" && result.BaseType != "" {
+		result.Name, err = generateValueTypeName(db, path)
 		result.Description = "generated"
 	}
 	return
 }
 
-var count = 0
-
-const maxCount = 10000000
-const TermSymbol = rdf.TermLiteral + 1
+const DefaultValueTypeNamePrefix = "generated"
 
-func getNextCount() int {
-	count = (count % maxCount) + 1
-	return count
-}
-
-func generateValueTypeName(valueType model.ValueType) (result string) {
-	return strconv.FormatInt(time.Now().Unix(), 10) + "_" + strconv.Itoa(getNextCount())
+//returns path or, if a value type with this name already exists, path with the first free numeric suffix
+func generateValueTypeName(db interfaces.Persistence, path string) (result string, err error) {
+	if path == "" {
+		path = DefaultValueTypeNamePrefix
+	}
+	result = path
+	for i := 2; ; i++ {
+		exists, err := db.ValueTypeNameExists(result)
+		if err != nil || !exists {
+			return result, err
+		}
+		result = path + "_" + strconv.Itoa(i)
+	}
 }
 
-func mapToValueType(db interfaces.Persistence, mapVal reflect.Value) (result model.ValueType, err error) {
+//fields missing in some samples are optional
+func structToValueType(db interfaces.Persistence, inferred *inferredType, path string) (result model.ValueType, err error) {
 	prevIsNew := false
 	result.BaseType = model.StructBaseType
-	for _, key := range mapVal.MapKeys() {
-		val := mapVal.MapIndex(key)
-		kind := reflect.ValueOf(val.Interface()).Kind()
-		if kind != reflect.Invalid {
-			subType, err := interfaceToValueType(db, val.Interface())
-			if err != nil {
-				log.Println("ERROR: mapToValueType() field ", key.String(), kind.String(), err)
-				return result, err
-			}
-			if subType.Id == "" && !prevIsNew {
+	keys := []string{}
+	for key := range inferred.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := inferred.Fields[key]
+		subType, err := inferredToValueType(db, field.Type, path+"."+key)
+		if err != nil {
+			log.Println("ERROR: structToValueType() field ", key, field.Type.Kind, err)
+			return result, err
+		}
+		if subType.Id != "" || subType.BaseType != "" {
+			if subType.Id == "" {
 				prevIsNew = true
 			}
-			if subType.Id != "" || subType.BaseType != "" {
-				result.Fields = append(result.Fields, model.FieldType{Name: key.String(), Type: subType})
-			}
+			result.Fields = append(result.Fields, model.FieldType{Name: key, Type: subType, Optional: field.Count < inferred.Samples})
 		}
 	}
 	if !prevIsNew {
@@ -116,19 +137,21 @@ func mapToValueType(db interfaces.Persistence, mapVal reflect.Value) (result mod
 	return
 }
 
-func sliceToValueType(db interfaces.Persistence, slice reflect.Value) (result model.ValueType, err error) {
-	length := slice.Len()
-	prevIsNew := false
-	if length > 0 {
-		result.BaseType = model.ListBaseType
-		subType, err := interfaceToValueType(db, slice.Index(0).Interface())
-		if err != nil {
-			return result, err
-		}
-		prevIsNew = subType.Id == ""
-		result.Fields = append(result.Fields, model.FieldType{Type: subType})
+//lists without any known element result in an empty value type
+func listToValueType(db interfaces.Persistence, inferred *inferredType, path string) (result model.ValueType, err error) {
+	if inferred.Element == nil {
+		return
 	}
-	if !prevIsNew {
+	result.BaseType = model.ListBaseType
+	subType, err := inferredToValueType(db, inferred.Element, path+"[]")
+	if err != nil {
+		return result, err
+	}
+	if subType.Id == "" && subType.BaseType == "" {
+		return model.ValueType{}, nil
+	}
+	result.Fields = append(result.Fields, model.FieldType{Name: inferred.ElementName, Type: subType})
+	if subType.Id != "" {
 		result, err = checkForExistingValueType(db, result)
 	}
 	return
@@ -147,12 +170,52 @@ func checkForExistingValueType(db interfaces.Persistence, vt model.ValueType) (r
 	return
 }
 
-func ValueTypeFromMessage(db interfaces.Persistence, msg string) (valueType model.ValueType, format Format, struc interface{}, err error) {
-	format, struc = getFormatStruct(msg)
-	if format == UNKNOWN_FORMAT {
-		err = errors.New("unknown message format; able to interprete the following formats: " + JSON_FORMAT)
-	} else {
-		valueType, err = interfaceToValueType(db, struc)
+//infers one value type from all samples; name is used as prefix for the names of new value types, fields extend it by their key path
+//conflicting types of the same field are reported in conflicts, the first seen type is used
+func ValueTypeFromMessages(db interfaces.Persistence, msgs []string, name string) (valueType model.ValueType, format Format, strucs []interface{}, conflicts []string, err error) {
+	inferred, format, strucs, conflicts, err := inferFromMessages(msgs, name)
+	if err != nil {
+		return
+	}
+	if name == "" {
+		name = DefaultValueTypeNamePrefix
+	}
+	valueType, err = inferredToValueType(db, inferred, name)
+	if err == nil && valueType.Id == "" && valueType.BaseType == "" {
+		err = errors.New("unable to infer value type from samples without values")
+	}
+	return
+}
+
+func inferFromMessages(msgs []string, name string) (inferred *inferredType, format Format, strucs []interface{}, conflicts []string, err error) {
+	if len(msgs) == 0 {
+		err = errors.New("missing message samples")
+		return
+	}
+	if name == "" {
+		name = DefaultValueTypeNamePrefix
+	}
+	inference := typeInference{}
+	for index, msg := range msgs {
+		msgFormat, struc := getFormatStruct(msg)
+		if msgFormat == UNKNOWN_FORMAT {
+			err = errors.New("unknown message format; able to interprete the following formats: " + JSON_FORMAT + ", " + XML_FORMAT)
+			return
+		}
+		if index == 0 {
+			format = msgFormat
+		} else if msgFormat != format {
+			err = errors.New("sample " + strconv.Itoa(index) + " has format " + string(msgFormat) + " but previous samples have format " + string(format))
+			return
+		}
+		strucs = append(strucs, struc)
+		sampleType, err := inference.infer(struc, name)
+		if err != nil {
+			return inferred, format, strucs, conflicts, err
+		}
+		inferred = inference.merge(inferred, sampleType, name)
 	}
+	conflicts = inference.Conflicts
+	sort.Strings(conflicts)
 	return
 }
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gen
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/format"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+//name of the field holding the text content of xml elements with attributes or child elements
+const XmlTextFieldName = "#text"
+
+type xmlNode struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Children   []xmlNode         `json:"children,omitempty"`
+	Text       string            `json:"text,omitempty"`
+}
+
+func parseXml(value string) (result xmlNode, err error) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "<") {
+		return result, errors.New("not a xml document")
+	}
+	decoder := xml.NewDecoder(strings.NewReader(value))
+	stack := []*xmlNode{}
+	found := false
+	var token xml.Token
+	for token, err = decoder.Token(); err == nil; token, err = decoder.Token() {
+		switch element := token.(type) {
+		case xml.StartElement:
+			if found && len(stack) == 0 {
+				return result, errors.New("more than one root element")
+			}
+			node := xmlNode{Name: element.Name.Local}
+			for _, attr := range element.Attr {
+				if node.Attributes == nil {
+					node.Attributes = map[string]string{}
+				}
+				node.Attributes[attr.Name.Local] = attr.Value
+			}
+			stack = append(stack, &node)
+			found = true
+		case xml.EndElement:
+			node := stack[len(stack)-1]
+			node.Text = strings.TrimSpace(node.Text)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				result = *node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, *node)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(element)
+			} else if strings.TrimSpace(string(element)) != "" {
+				return result, errors.New("text outside of root element")
+			}
+		}
+	}
+	if err != io.EOF {
+		return result, err
+	}
+	if !found || len(stack) > 0 {
+		return result, errors.New("incomplete xml document")
+	}
+	return result, nil
+}
+
+//texts are interpreted as integer, float or boolean if possible
+func inferXmlText(text string) *inferredType {
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return &inferredType{Kind: inferredInt}
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return &inferredType{Kind: inferredFloat}
+	}
+	if text == "true" || text == "false" {
+		return &inferredType{Kind: inferredBool}
+	}
+	return &inferredType{Kind: inferredString}
+}
+
+//elements without attributes and child elements are primitives; repeated child elements result in anonymous lists
+func (this *typeInference) inferXml(node xmlNode, path string) (result *inferredType) {
+	if len(node.Attributes) == 0 && len(node.Children) == 0 {
+		return inferXmlText(node.Text)
+	}
+	result = &inferredType{Kind: inferredStruct, Samples: 1, Fields: map[string]*inferredField{}}
+	for name, value := range node.Attributes {
+		result.Fields[name] = &inferredField{Type: inferXmlText(value), Count: 1, Flags: []string{format.XmlAttrFlag}}
+	}
+	children := map[string][]xmlNode{}
+	for _, child := range node.Children {
+		children[child.Name] = append(children[child.Name], child)
+	}
+	for name, elements := range children {
+		if len(elements) == 1 {
+			result.Fields[name] = &inferredField{Type: this.inferXml(elements[0], path+"."+name), Count: 1}
+		} else {
+			list := &inferredType{Kind: inferredList, ElementName: name}
+			for _, element := range elements {
+				list.Element = this.merge(list.Element, this.inferXml(element, path+"."+name+"[]"), path+"."+name+"[]")
+			}
+			result.Fields[name] = &inferredField{Type: list, Count: 1, Flags: []string{format.XmlAnonym}}
+		}
+	}
+	if node.Text != "" {
+		result.Fields[XmlTextFieldName] = &inferredField{Type: inferXmlText(node.Text), Count: 1, Flags: []string{format.XmlAnonym}}
+	}
+	return
+}
+
+//collects the xml flags of the inferred fields; new fields of valueType need ids
+func xmlFormatInfo(db interfaces.Persistence, valueType model.ValueType, inferred *inferredType) (result []model.AdditionalFormatInfo, err error) {
+	if inferred == nil || (inferred.Kind != inferredStruct && inferred.Kind != inferredList) {
+		return
+	}
+	if valueType.Id != "" && len(valueType.Fields) == 0 {
+		valueType, err = db.GetValueTypeById(valueType.Id)
+		if err != nil {
+			return
+		}
+	}
+	for _, field := range valueType.Fields {
+		var fieldType *inferredType
+		if inferred.Kind == inferredList {
+			fieldType = inferred.Element
+		} else if inferredField, ok := inferred.Fields[field.Name]; ok {
+			fieldType = inferredField.Type
+			if len(inferredField.Flags) > 0 {
+				if field.Id == "" {
+					return result, errors.New("missing id of field " + field.Name)
+				}
+				result = append(result, model.AdditionalFormatInfo{Field: model.FieldType{Id: field.Id}, FormatFlag: strings.Join(inferredField.Flags, ",")})
+			}
+		}
+		subInfo, err := xmlFormatInfo(db, field.Type, fieldType)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, subInfo...)
+	}
+	return
+}
@@ -41,9 +41,12 @@ type Persistence interface {
 	GetDeviceTypeById(id string, depth int) (model.DeviceType, error)
 	DeviceTypeIdExists(string) bool
 	SetDeviceType(dt model.DeviceType) error
+	SetReviewedDeviceType(dt model.DeviceType) error
 	DeleteDeviceType(string) error
 	DeviceTypeQuery(deviceType model.DeviceType) (exists bool, id string, err error)
 	QueryServiceDeviceType(service model.Service) (typeIds []string, err error)
+	GetAcceptedDeviceType(id string, review model.DeviceTypeReview) (deviceType model.DeviceType, changedValueTypes []string, err error)
+	CheckDeviceTypeReject(id string) (err error)
 
 	//DeviceInstance Methods
 
@@ -61,10 +64,17 @@ type Persistence interface {
 	//ValueType Methods
 	GetValueTypeList(limit int, offset int) ([]model.ValueType, error)
 	GetValueTypeById(id string) (model.ValueType, error)
+	ResolveValueTypeInheritance(valueType model.ValueType) (model.ValueType, error)
 	ValueTypeQuery(valueType model.ValueType) (exists bool, id string, err error)
+	ValueTypeNameExists(name string) (exists bool, err error)
 	CreateValueType(model.ValueType) (err error)
 	DeleteValueType(id string) (err error)
 	CheckValueTypeDelete(id string) (err error)
+	GetValueTypeUsages(id string) (usages []model.ValueTypeUsage, err error)
+	FindEquivalentValueTypes() (clusters []model.ValueTypeCluster, err error)
+	CheckValueTypeMerge(canonical string, duplicates []string) (err error)
+	MergeValueTypes(canonical string, duplicates []string) (err error)
+	RenameValueType(id string, name string) (err error)
 	ValueTypeIsConsistent(valueType model.ValueType) (err error)
 	ValueTypeIdExists(id string) (exists bool, err error)
 
@@ -82,23 +92,53 @@ type Persistence interface {
 	DeleteGateway(id string) error
 	GetGatewayName(id string) (name string, err error)
 	GetGatewayNameByDevice(id string) (name string, err error)
-	SetGatway(id string, name string, hash string, devices []string) (err error)
+	SetGatway(id string, name string, hash string, devices []string, gateways []string) (err error)
+	GetGatewayTree(id string) (tree model.GatewayTree, err error)
+	GetGatewayConnection(id string) (connection model.GatewayConnection, err error)
+	SetGatewayConnection(connection model.GatewayConnection) (err error)
+	GetOnlineGatewayConnections() (connections []model.GatewayConnection, err error)
+	GetGatewaySync(id string, requestHash string) (result model.GatewaySyncResponse, current model.GatewaySyncState, stored model.GatewaySyncState, err error)
+	GetGatewaySyncState(id string) (state model.GatewaySyncState, err error)
+	SetGatewaySyncState(state model.GatewaySyncState) (err error)
 	ProvideGateway(id string, owner string) (gateway model.Gateway, isNew bool, err error)
 	GatewayCheckCommit(id string, ref model.GatewayRef) (err error)
 	CheckClearGateway(id string) error
+	ProvideGatewayClaimToken(token model.GatewayClaimToken) (result model.GatewayClaimToken, err error)
+	GetGatewayClaimTokenByHash(hash string) (token model.GatewayClaimToken, err error)
+	GetGatewayClaimTokens(gatewayId string) (tokens []model.GatewayClaimToken, err error)
+	SetGatewayClaimToken(token model.GatewayClaimToken) (err error)
+	DeleteGatewayClaimTokens(gatewayId string) (err error)
+	ProvideGatewayCredential(credential model.GatewayCredential) (result model.GatewayCredential, err error)
+	GetGatewayCredential(id string) (credential model.GatewayCredential, err error)
+	GetGatewayCredentials(gatewayId string) (credentials []model.GatewayCredential, err error)
+	GetGatewayCredentialByHash(hash string) (credential model.GatewayCredential, err error)
+	GetGatewayCredentialBySubject(subject string) (credential model.GatewayCredential, err error)
+	SetGatewayCredential(credential model.GatewayCredential) (err error)
+	DeleteGatewayCredential(id string) (err error)
 
 	//endpoint
 	DeleteEndpoints(deviceid string) (err error)
 	UpdateDeviceEndpoints(device model.DeviceInstance) error     //delete, update, insert
 	UpdateDeviceTypeEndpoints(deviceType model.DeviceType) error //delete, update, insert
 	GetEndpoints(endpoint string, protocolHandler string) (result []model.Endpoint, err error)
-	GetEndpointByDeviceAndService(deviceId string, serviceId string) (result model.Endpoint, err error)
+	GetEndpointsByList(endpoints []string, protocolHandler string) (result []model.Endpoint, err error)
+	GetEndpointByDeviceAndService(deviceId string, serviceId string, protocolHandler string, role string) (result model.Endpoint, err error)
 	GetEndpointsList(limit, offset int) (result []model.Endpoint, err error)
 
 	GetProtocolByUri(uri string) (result model.Protocol, err error)
 
-	FlushDevices()
-	FlushGateways()
-	FlushDevicetypes()
-	FlushValueTypes()
+	//auth entries of the embedded permission engine
+	GetAuth(id string) (auth model.Auth, err error)
+	SetAuth(auth model.Auth) (err error)
+	DeleteAuth(id string) (err error)
+	GetAuths(kind string) (auths []model.Auth, err error)
+	GetAuthDocuments(kind string) (documents map[string]model.AuthDocument, err error)
+	BackfillAuth(owner string) (count int, err error)
+
+	GetFlushIds(kind string) (ids []string, err error)
+	Flush(kind string, id string) (err error)
+	StartFlush(request model.FlushRequest) (job model.FlushJob, err error)
+	GetFlushJob(id string) (job model.FlushJob, err error)
+	ListFlushJobs() (result []model.FlushJob)
+	CancelFlushJob(id string) (err error)
 }
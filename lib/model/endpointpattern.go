@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+//endpoint patterns are endpoint strings with wildcard or parameter segments (segments are separated by '/'):
+//	+       matches one segment; captured as parameter "1", "2", ... in order of appearance
+//	#       matches all remaining segments (only as last segment); captured as parameter "#"
+//	{name}  matches one segment; captured as parameter "name"
+const (
+	EndpointSegmentSeparator = "/"
+	EndpointSingleWildcard   = "+"
+	EndpointMultiWildcard    = "#"
+)
+
+const (
+	segmentMultiWildcard = iota
+	segmentSingleWildcard
+	segmentParameter
+	segmentLiteral
+)
+
+func endpointSegmentKind(segment string) int {
+	switch {
+	case segment == EndpointMultiWildcard:
+		return segmentMultiWildcard
+	case segment == EndpointSingleWildcard:
+		return segmentSingleWildcard
+	case len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+		return segmentParameter
+	default:
+		return segmentLiteral
+	}
+}
+
+func IsEndpointPattern(endpoint string) bool {
+	for _, segment := range strings.Split(endpoint, EndpointSegmentSeparator) {
+		if endpointSegmentKind(segment) != segmentLiteral {
+			return true
+		}
+	}
+	return false
+}
+
+//returns the captured parameters if endpoint matches pattern
+func MatchEndpointPattern(pattern string, endpoint string) (params map[string]string, ok bool) {
+	patternSegments := strings.Split(pattern, EndpointSegmentSeparator)
+	endpointSegments := strings.Split(endpoint, EndpointSegmentSeparator)
+	params = map[string]string{}
+	wildcards := 0
+	for index, segment := range patternSegments {
+		kind := endpointSegmentKind(segment)
+		if kind == segmentMultiWildcard {
+			if index != len(patternSegments)-1 {
+				return nil, false
+			}
+			if index < len(endpointSegments) {
+				params[EndpointMultiWildcard] = strings.Join(endpointSegments[index:], EndpointSegmentSeparator)
+			} else {
+				params[EndpointMultiWildcard] = ""
+			}
+			return params, true
+		}
+		if index >= len(endpointSegments) {
+			return nil, false
+		}
+		switch kind {
+		case segmentSingleWildcard:
+			wildcards++
+			params[strconv.Itoa(wildcards)] = endpointSegments[index]
+		case segmentParameter:
+			params[segment[1:len(segment)-1]] = endpointSegments[index]
+		default:
+			if segment != endpointSegments[index] {
+				return nil, false
+			}
+		}
+	}
+	if len(patternSegments) != len(endpointSegments) {
+		return nil, false
+	}
+	return params, true
+}
+
+//precedence of overlapping patterns: > 0 if a is more specific than b, < 0 if b is more specific, 0 if equal
+//the first differing segment decides (literal > {name} > + > #); otherwise the longer pattern wins
+func CompareEndpointPatterns(a string, b string) int {
+	aSegments := strings.Split(a, EndpointSegmentSeparator)
+	bSegments := strings.Split(b, EndpointSegmentSeparator)
+	for index := 0; index < len(aSegments) && index < len(bSegments); index++ {
+		aKind := endpointSegmentKind(aSegments[index])
+		bKind := endpointSegmentKind(bSegments[index])
+		if aKind != bKind {
+			return aKind - bKind
+		}
+	}
+	return len(aSegments) - len(bSegments)
+}
+
+//returns the pattern endpoints with the highest precedence matching endpoint
+//Endpoint is set to the resolved string, MatchedPattern to the pattern and Params to the captured parameters
+func ResolveEndpointPatterns(endpoint string, patterns []Endpoint) (result []Endpoint) {
+	result = []Endpoint{}
+	for _, pattern := range patterns {
+		params, ok := MatchEndpointPattern(pattern.Endpoint, endpoint)
+		if !ok {
+			continue
+		}
+		if len(result) > 0 {
+			precedence := CompareEndpointPatterns(pattern.Endpoint, result[0].MatchedPattern)
+			if precedence < 0 {
+				continue
+			}
+			if precedence > 0 {
+				result = []Endpoint{}
+			}
+		}
+		pattern.MatchedPattern = pattern.Endpoint
+		pattern.Endpoint = endpoint
+		pattern.Params = params
+		result = append(result, pattern)
+	}
+	return
+}
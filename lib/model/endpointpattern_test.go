@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+func Example_endpointPatterns() {
+	fmt.Println(IsEndpointPattern("sensors/dev1/temp/state"), IsEndpointPattern("sensors/dev1/+/state"), IsEndpointPattern("sensors/{channel}/state"))
+
+	params, ok := MatchEndpointPattern("sensors/dev1/+/{field}/#", "sensors/dev1/temp/state/a/b")
+	fmt.Println(ok, printParams(params))
+	params, ok = MatchEndpointPattern("sensors/dev1/#", "sensors/dev1")
+	fmt.Println(ok, printParams(params))
+	_, ok = MatchEndpointPattern("sensors/dev1/+/state", "sensors/dev1/temp")
+	fmt.Println(ok)
+	_, ok = MatchEndpointPattern("sensors/dev1/+", "sensors/dev1/temp/state")
+	fmt.Println(ok)
+
+	patterns := []Endpoint{
+		{Id: "multi", Endpoint: "sensors/#"},
+		{Id: "wildcard", Endpoint: "sensors/+/state"},
+		{Id: "param", Endpoint: "sensors/{device}/state"},
+		{Id: "literal", Endpoint: "sensors/dev1/+"},
+		{Id: "literal2", Endpoint: "sensors/dev1/{field}"},
+		{Id: "other", Endpoint: "actuators/+/state"},
+	}
+	for _, endpoint := range []string{"sensors/dev1/state", "sensors/dev2/state", "sensors/dev2/temp", "actuators/dev1/state/x"} {
+		resolved := ResolveEndpointPatterns(endpoint, patterns)
+		fmt.Print(endpoint, ":")
+		for _, match := range resolved {
+			fmt.Print(" ", match.Id, "=", match.MatchedPattern, " (", printParams(match.Params), ")")
+		}
+		fmt.Println()
+	}
+
+	//Output:
+	//false true true
+	//true #=a/b 1=temp field=state
+	//true #=
+	//false
+	//false
+	//sensors/dev1/state: literal2=sensors/dev1/{field} (field=state)
+	//sensors/dev2/state: param=sensors/{device}/state (device=dev2)
+	//sensors/dev2/temp: multi=sensors/# (#=dev2/temp)
+	//actuators/dev1/state/x:
+}
+
+func printParams(params map[string]string) (result string) {
+	keys := []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if result != "" {
+			result += " "
+		}
+		result += key + "=" + params[key]
+	}
+	return
+}
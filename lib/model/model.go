@@ -16,6 +16,8 @@
 
 package model
 
+import "time"
+
 //TODO: User/Owner and time informations
 
 // mgo uses bson and not json --> field names are lowercases (FlowId -> flowid)
@@ -32,17 +34,25 @@ const (
 
 type Auth struct {
 	ResourceId  string       `json:"resource_id"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Auth"`
+	Kind        string       `json:"kind,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#resourceKind"`
 	Owner       string       `json:"owner"                               rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#owner"`
 	Permissions []Permission `json:"permissions"                         rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#grandsPermissions"`
 }
 
 type Permission struct {
-	Id      string `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Permission"`
-	Role    string `json:"role,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#role"`
-	User    string `json:"user,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#user"`
-	Read    bool   `json:"read"                                rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#read"`
-	Write   bool   `json:"write"                               rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#write"`
-	Execute bool   `json:"execute"                             rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#execute"`
+	Id           string `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Permission"`
+	Role         string `json:"role,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#role"`
+	User         string `json:"user,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#user"`
+	Read         bool   `json:"read"                                rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#read"`
+	Write        bool   `json:"write"                               rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#write"`
+	Execute      bool   `json:"execute"                             rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#execute"`
+	Administrate bool   `json:"administrate"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#administrate"`
+}
+
+//name and search fields of a resource, named like the fields of the permissionsearch index
+type AuthDocument struct {
+	Name   string              `json:"name"`
+	Fields map[string][]string `json:"fields"`
 }
 
 type MsgSegment struct {
@@ -81,23 +91,60 @@ type UltraShortDeviceType struct {
 }
 
 type Service struct {
-	Id             string           `json:"id,omitempty"                           rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Service"`
-	ServiceType    string           `json:"service_type,omitempty"       rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasServiceType"` // "Actuator" || "Sensor"
-	Name           string           `json:"name,omitempty"                                 rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
-	Description    string           `json:"description,omitempty"                          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#description"`
-	Protocol       Protocol         `json:"protocol,omitempty"                             rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasProtocol"`
-	Input          []TypeAssignment `json:"input,omitempty"                                rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasInput"`
-	Output         []TypeAssignment `json:"output,omitempty"                               rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasOutput"` // list of alternative result types; for example a string if success or a json on error
-	Url            string           `json:"url,omitempty"                                  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#url"`
-	EndpointFormat string           `json:"endpoint_format,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpoint_format"`
+	Id              string           `json:"id,omitempty"                           rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Service"`
+	ServiceType     string           `json:"service_type,omitempty"       rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasServiceType"` // "Actuator" || "Sensor"
+	Name            string           `json:"name,omitempty"                                 rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
+	Description     string           `json:"description,omitempty"                          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#description"`
+	Protocol        Protocol         `json:"protocol,omitempty"                             rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasProtocol"`
+	Input           []TypeAssignment `json:"input,omitempty"                                rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasInput"`
+	Output          []TypeAssignment `json:"output,omitempty"                               rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasOutput"` // list of alternative result types; for example a string if success or a json on error
+	Url             string           `json:"url,omitempty"                                  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#url"`
+	EndpointFormat  string           `json:"endpoint_format,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpoint_format"`
+	EndpointFormats []EndpointFormat `json:"endpoint_formats,omitempty"                     rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasEndpointFormat"` //additional endpoint formats (e.g. other topics or protocol handlers)
+}
+
+type EndpointFormat struct {
+	Id              string `json:"id,omitempty"                rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#EndpointFormat"`
+	Format          string `json:"format"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpoint_format"`
+	ProtocolHandler string `json:"protocol_handler,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#protocol_handler"` //defaults to the protocol handler of the service protocol
+	Role            string `json:"role,omitempty"              rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpointRole"`     //e.g. "state" or "telemetry"; selects the outbound endpoint
+}
+
+//all endpoint formats of the service; endpoint_format is the format without role of the service protocol handler
+func (service Service) GetEndpointFormats() (result []EndpointFormat) {
+	result = []EndpointFormat{}
+	if service.EndpointFormat != "" {
+		result = append(result, EndpointFormat{Format: service.EndpointFormat, ProtocolHandler: service.Protocol.ProtocolHandlerUrl})
+	}
+	for _, format := range service.EndpointFormats {
+		if format.ProtocolHandler == "" {
+			format.ProtocolHandler = service.Protocol.ProtocolHandlerUrl
+		}
+		result = append(result, format)
+	}
+	return
 }
 
 type Endpoint struct {
-	Id              string `json:"id"                                  rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Endpoint"`
-	Endpoint        string `json:"endpoint"                            rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpoint"`
-	Service         string `json:"service"           rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refService"`
-	Device          string `json:"device"            rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refDevice"`
-	ProtocolHandler string `json:"protocol_handler"                    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#protocol_handler"`
+	Id              string            `json:"id"                                  rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Endpoint"`
+	Endpoint        string            `json:"endpoint"                            rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpoint"`
+	Service         string            `json:"service"           rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refService"`
+	Device          string            `json:"device"            rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refDevice"`
+	ProtocolHandler string            `json:"protocol_handler"                    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#protocol_handler"`
+	ServiceType     string            `json:"service_type,omitempty" rdf_ref:"true" rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refServiceType"` //copy of the service type
+	Protocol        string            `json:"protocol,omitempty"     rdf_ref:"true" rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#refProtocol"`    //copy of the service protocol id
+	Role            string            `json:"role,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#endpointRole"`     //role of the endpoint format
+	Pattern         bool              `json:"pattern,omitempty"      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#isPattern"`                     //endpoint contains wildcard or parameter segments
+	MatchedPattern  string            `json:"matched_pattern,omitempty"`                                                                                                  //pattern an endpoint string was resolved with; Endpoint then holds the resolved string
+	Params          map[string]string `json:"params,omitempty"`                                                                                                           //parameters captured while resolving an endpoint string against a pattern
+}
+
+const SensorServiceType = "http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Sensor"
+
+//resolves many endpoint strings of one protocol handler at once
+type EndpointResolveRequest struct {
+	ProtocolHandler string   `json:"protocol_handler"`
+	Endpoints       []string `json:"endpoints"`
 }
 
 type AdditionalFormatInfo struct {
@@ -141,23 +188,127 @@ type DeviceInstance struct {
 }
 
 type GatewayRef struct {
-	Id      string   `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
-	Devices []string `json:"devices,omitempty"     rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
-	Hash    string   `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
+	Id       string   `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Devices  []string `json:"devices,omitempty"     rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
+	Gateways []string `json:"gateways,omitempty"    rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsGateways"` //nil keeps the connected gateways
+	Hash     string   `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
 }
 
 type GatewayFlat struct {
-	Id      string   `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
-	Devices []string `json:"devices,omitempty"     rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
-	Hash    string   `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
-	Name    string   `json:"name,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
+	Id       string   `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Devices  []string `json:"devices,omitempty"     rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
+	Gateways []string `json:"gateways,omitempty"    rdf_ref:"true"          rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsGateways"`
+	Hash     string   `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
+	Name     string   `json:"name,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
 }
 
 type Gateway struct {
-	Id      string           `json:"id,omitempty"               rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
-	Name    string           `json:"name,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
-	Hash    string           `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
-	Devices []DeviceInstance `json:"devices,omitempty"                     rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
+	Id       string           `json:"id,omitempty"               rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Name     string           `json:"name,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
+	Hash     string           `json:"hash,omitempty"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hash"`
+	Devices  []DeviceInstance `json:"devices,omitempty"                     rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsDevices"`
+	Gateways []string         `json:"gateways,omitempty"    rdf_ref:"true"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectsGateways"`
+	Parent   string           `json:"parent,omitempty"      rdf_ref:"true"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectedByGateway"`
+	Online   bool             `json:"online"                                rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#online"`
+	LastSeen string           `json:"last_seen,omitempty"                   rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#lastSeen"`
+	Version  string           `json:"version,omitempty"                     rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#softwareVersion"`
+}
+
+//connection state of a gateway reported by heartbeats
+type GatewayConnection struct {
+	Id       string `json:"id"                             rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Online   bool   `json:"online"                         rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#online"`
+	LastSeen string `json:"last_seen,omitempty"            rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#lastSeen"` //RFC3339 (UTC)
+	Version  string `json:"version,omitempty"              rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#softwareVersion"`
+}
+
+//gateway connected by a other gateway
+type GatewayParentRelation struct {
+	Id     string `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Parent string `json:"parent,omitempty"          rdf_ref:"true"      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectedByGateway"`
+}
+
+//last state delivered to a gateway by a sync
+type GatewaySyncState struct {
+	Id      string   `json:"id"                             rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#Gateway" rdf_root:"true"`
+	Hash    string   `json:"hash,omitempty"                 rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#syncHash"`
+	Devices []string `json:"devices,omitempty"              rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#syncDevice"` //"<device id> <device hash>"
+}
+
+//device of a gateway with its resolved device type and endpoints
+type GatewaySyncDevice struct {
+	Device     DeviceInstance `json:"device"`
+	DeviceType DeviceType     `json:"device_type"`
+	Endpoints  []Endpoint     `json:"endpoints"`
+	Hash       string         `json:"hash"`
+}
+
+type GatewaySyncRequest struct {
+	Hash string `json:"hash"`
+}
+
+type GatewaySyncResponse struct {
+	Hash    string              `json:"hash"`
+	Full    bool                `json:"full"` //added contains all devices; devices unknown to the response have to be removed
+	Added   []GatewaySyncDevice `json:"added"`
+	Changed []GatewaySyncDevice `json:"changed"`
+	Removed []string            `json:"removed"`
+}
+
+type GatewayTree struct {
+	Id       string           `json:"id"`
+	Name     string           `json:"name"`
+	Hash     string           `json:"hash,omitempty"`
+	Parent   string           `json:"parent,omitempty"`
+	Online   bool             `json:"online"`
+	LastSeen string           `json:"last_seen,omitempty"`
+	Devices  []DeviceInstance `json:"devices"`
+	Gateways []GatewayTree    `json:"gateways"`
+}
+
+const (
+	GatewayCredentialKey = "key" //api key sent by the gateway as X-Gateway-Key header
+	GatewayCredentialJwt = "jwt" //subject of jwts issued for the gateway
+)
+
+//one-time token to claim a credential for a gateway; only the sha256 hash of the token is stored
+type GatewayClaimToken struct {
+	Id      string `json:"id"                             rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#GatewayClaimToken" rdf_root:"true"`
+	Gateway string `json:"gateway"       rdf_ref:"true"   rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#claimsGateway"`
+	Hash    string `json:"hash"                           rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#claimTokenHash"`
+	Creator string `json:"creator"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#claimTokenCreator"`
+	Expires string `json:"expires"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#claimTokenExpires"` //RFC3339 (UTC)
+}
+
+//identity of a gateway; keys are stored as sha256 hash, jwt credentials by their subject
+type GatewayCredential struct {
+	Id      string `json:"id"                             rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#GatewayCredential" rdf_root:"true"`
+	Gateway string `json:"gateway"       rdf_ref:"true"   rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#credentialOf"`
+	Kind    string `json:"kind"                           rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#credentialKind"`
+	Hash    string `json:"hash,omitempty"                 rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#credentialHash"`
+	Subject string `json:"subject,omitempty"              rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#credentialSubject"`
+	Created string `json:"created"                        rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#credentialCreated"` //RFC3339 (UTC)
+}
+
+type GatewayClaimRequest struct {
+	Token string `json:"token"`
+	Kind  string `json:"kind"` //GatewayCredentialKey (default) or GatewayCredentialJwt; jwt credentials use the subject of the jwt of the claim request
+}
+
+//tokens and keys are only returned once and can not be recovered
+type GatewayClaimTokenResponse struct {
+	Id      string `json:"id"`
+	Gateway string `json:"gateway"`
+	Token   string `json:"token"`
+	Expires string `json:"expires"`
+}
+
+type GatewayCredentialResponse struct {
+	Id      string `json:"id"`
+	Gateway string `json:"gateway"`
+	Kind    string `json:"kind"`
+	Key     string `json:"key,omitempty"`
+	Subject string `json:"subject,omitempty"`
 }
 
 type SmartObject struct {
@@ -192,9 +343,10 @@ type ConfigFieldType struct {
 }
 
 type FieldType struct {
-	Id   string    `json:"id,omitempty"    rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#FieldType"`
-	Name string    `json:"name,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
-	Type ValueType `json:"type,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasValueType"`
+	Id       string    `json:"id,omitempty"    rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#FieldType"`
+	Name     string    `json:"name,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
+	Type     ValueType `json:"type,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasValueType"`
+	Optional bool      `json:"optional,omitempty"  rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#optional"` //field may be missing in messages
 }
 
 type ValueType struct {
@@ -202,10 +354,94 @@ type ValueType struct {
 	Name        string      `json:"name,omitempty"                             rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#name"`
 	Description string      `json:"description,omitempty"                      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#description"`
 	BaseType    string      `json:"base_type,omitempty"      rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasBaseType"`
+	Extends     string      `json:"extends,omitempty"        rdf_ref:"true"    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#extends"` //id of a struct value type whose fields are inherited
 	Fields      []FieldType `json:"fields"                           rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasField"`
 	Literal     string      `json:"literal"                                    rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#hasLiteral"` //is literal, if not empty
 }
 
+const (
+	UsageValueType          = "value_type"
+	UsageExtendingValueType = "extending_value_type"
+	UsageField              = "field"
+	UsageTypeAssignment     = "type_assignment"
+	UsageService            = "service"
+	UsageDeviceType         = "device_type"
+)
+
+type ValueTypeUsage struct {
+	Kind   string   `json:"kind"`
+	Id     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Direct bool     `json:"direct"`
+	Path   []string `json:"path"` //ids from the used value type to the referencing element
+}
+
+type ValueTypeCluster struct {
+	Signature  string      `json:"signature"`
+	ValueTypes []ValueType `json:"value_types"`
+}
+
+type ValueTypeMerge struct {
+	Canonical  string   `json:"canonical"`
+	Duplicates []string `json:"duplicates"`
+}
+
+const (
+	MaintenanceRename          = "rename"              //generated device type waits for review
+	GeneratedDeviceInstanceTag = "generated:generated" //device instance was created by endpoint generation
+)
+
+//accepts a generated device type
+type DeviceTypeReview struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Vendor         string            `json:"vendor"`                     //vendor id
+	DeviceClass    string            `json:"device_class"`               //device class id
+	ValueTypeNames map[string]string `json:"value_type_names,omitempty"` //new names of value types used by the device type (by value type id)
+	FieldNames     map[string]string `json:"field_names,omitempty"`      //new names of type assignments and value type fields (by type assignment or field id)
+}
+
+const (
+	KindDeviceType     = "deviceType"
+	KindDeviceInstance = "deviceInstance"
+	KindGateway        = "gateway"
+	KindValueType      = "valueType"
+)
+
+const (
+	FlushRunning  = "running"
+	FlushFinished = "finished"
+	FlushCanceled = "canceled"
+	FlushFailed   = "failed" //the ids of a kind could not be loaded
+)
+
+//publishes the current state of entities of one kind or of multiple kinds in the given order
+type FlushRequest struct {
+	Kind      string   `json:"kind,omitempty"`
+	Kinds     []string `json:"kinds,omitempty"`      //flushed sequentially; instead of kind
+	Ids       []string `json:"ids,omitempty"`        //all entities of the kind if empty; only for a single kind
+	RateLimit float64  `json:"rate_limit,omitempty"` //entities per second; 0 = unlimited
+}
+
+type FlushError struct {
+	Kind  string `json:"kind"`
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+type FlushJob struct {
+	Id       string       `json:"id"`
+	Request  FlushRequest `json:"request"`
+	State    string       `json:"state"`
+	Total    int          `json:"total"`
+	Done     int          `json:"done"`
+	Failed   int          `json:"failed"`
+	Errors   []FlushError `json:"errors,omitempty"`
+	Error    string       `json:"error,omitempty"` //the job could not be executed (e.g. ids could not be loaded)
+	Started  time.Time    `json:"started"`
+	Finished *time.Time   `json:"finished,omitempty"`
+}
+
 type DeviceGatewayRelation struct {
 	Id      string `json:"id,omitempty"                        rdf_entity:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#DeviceInstance" rdf_root:"true"`
 	Gateway string `json:"gateway,omitempty"         rdf_ref:"true"      rdf_field:"http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#connectedByGateway"`
@@ -31,6 +31,19 @@ func (service Service) IsValid() (valid bool, error string){
 	if len(service.Description) == 0 {
 		return false, "missing service description"
 	}
+	//protocol handler and role select the outbound endpoint and have to be unique;
+	//formats without protocol handler use the handler of the service protocol
+	roles := map[string]bool{}
+	for _, format := range service.GetEndpointFormats() {
+		if format.Format == "" {
+			return false, "missing format in endpoint format"
+		}
+		key := format.ProtocolHandler + "/" + format.Role
+		if roles[key] {
+			return false, "duplicate endpoint format role '" + format.Role + "'"
+		}
+		roles[key] = true
+	}
 	return true, error
 }
 
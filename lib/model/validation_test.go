@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "fmt"
+
+func Example_serviceEndpointFormatRoles() {
+	service := Service{
+		Name:           "s",
+		Description:    "d",
+		Url:            "url",
+		Protocol:       Protocol{Id: "p", ProtocolHandlerUrl: "mqtt"},
+		EndpointFormat: "{{device_uri}}/{{service_uri}}",
+	}
+	printValid(service.IsValid())
+
+	service.EndpointFormats = []EndpointFormat{{Format: "{{device_uri}}/state", Role: "state"}, {Format: "{{device_uri}}/{{service_uri}}", ProtocolHandler: "http"}}
+	printValid(service.IsValid())
+
+	//same handler as the service protocol
+	service.EndpointFormats = []EndpointFormat{{Format: "{{device_uri}}/cmd", ProtocolHandler: "mqtt"}}
+	printValid(service.IsValid())
+
+	service.EndpointFormats = []EndpointFormat{{Format: "{{device_uri}}/state", Role: "state"}, {Format: "{{device_uri}}/status", ProtocolHandler: "mqtt", Role: "state"}}
+	printValid(service.IsValid())
+
+	service.EndpointFormats = []EndpointFormat{{Role: "state"}}
+	printValid(service.IsValid())
+
+	//Output:
+	//true ""
+	//true ""
+	//false "duplicate endpoint format role ''"
+	//false "duplicate endpoint format role 'state'"
+	//false "missing format in endpoint format"
+}
+
+func printValid(valid bool, error string) {
+	fmt.Printf("%v %q\n", valid, error)
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+//command of the permission service; grants the right ("r", "w", "x", "a" combined) to a user or a group (realm role)
+type PermissionCommand struct {
+	Command       string `json:"command"` //"PUT" or "DELETE"
+	Kind          string `json:"kind"`
+	Resource      string `json:"resource"`
+	User          string `json:"user"`
+	Group         string `json:"group"`
+	Right         string `json:"right"`
+	CorrelationId string `json:"correlation_id,omitempty"`
+}
+
+//rights of a resource as reported by the permissionsearch service
+type ResourceRights struct {
+	ResourceId  string           `json:"resource_id"`
+	UserRights  map[string]Right `json:"user_rights"`
+	GroupRights map[string]Right `json:"group_rights"`
+}
+
+type Right struct {
+	Read         bool `json:"read"`
+	Write        bool `json:"write"`
+	Execute      bool `json:"execute"`
+	Administrate bool `json:"administrate"`
+}
+
+//returns the owner and the granted permissions of the resource
+//permissionsearch knows no owner; users with administrate right are listed as permissions with "administrate": true
+func GetAuth(jwt jwt_http_router.Jwt, kind string, id string) (auth model.Auth, err error) {
+	if engine != nil {
+		return engine.GetAuth(kind, id)
+	}
+	// /administrate/rights/:resource_kind/:resource
+	rights := ResourceRights{}
+	err = jwt.Impersonate.GetJSON(util.Config.PermissionsUrl+"/administrate/rights/"+url.QueryEscape(kind)+"/"+url.QueryEscape(id), &rights)
+	if err != nil {
+		return auth, err
+	}
+	auth = model.Auth{ResourceId: id, Kind: kind, Permissions: []model.Permission{}}
+	users := []string{}
+	for user := range rights.UserRights {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		right := rights.UserRights[user]
+		auth.Permissions = append(auth.Permissions, model.Permission{User: user, Read: right.Read, Write: right.Write, Execute: right.Execute, Administrate: right.Administrate})
+	}
+	groups := []string{}
+	for group := range rights.GroupRights {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		right := rights.GroupRights[group]
+		auth.Permissions = append(auth.Permissions, model.Permission{Role: group, Read: right.Read, Write: right.Write, Execute: right.Execute, Administrate: right.Administrate})
+	}
+	return auth, nil
+}
+
+//each permission names either a user or a role; permissions without rights are removed
+func NormalizePermissions(permissions []model.Permission) (result []model.Permission, err error) {
+	result = []model.Permission{}
+	index := map[string]int{}
+	for _, permission := range permissions {
+		if (permission.User == "") == (permission.Role == "") {
+			return result, errors.New("permission needs either user or role")
+		}
+		if permissionRight(permission) == "" {
+			continue
+		}
+		key := permissionKey(permission)
+		if i, ok := index[key]; ok {
+			return result, errors.New("multiple permissions for " + key + ": " + result[i].User + result[i].Role)
+		}
+		index[key] = len(result)
+		result = append(result, model.Permission{User: permission.User, Role: permission.Role, Read: permission.Read, Write: permission.Write, Execute: permission.Execute, Administrate: permission.Administrate})
+	}
+	return result, nil
+}
+
+func permissionKey(permission model.Permission) string {
+	if permission.User != "" {
+		return "user " + permission.User
+	}
+	return "role " + permission.Role
+}
+
+func permissionRight(permission model.Permission) (right string) {
+	if permission.Read {
+		right = right + "r"
+	}
+	if permission.Write {
+		right = right + "w"
+	}
+	if permission.Execute {
+		right = right + "x"
+	}
+	if permission.Administrate {
+		right = right + "a"
+	}
+	return
+}
+
+//commands changing the permissions of old to the permissions of new; revoked permissions are deleted before changed permissions are put
+func PermissionCommands(kind string, id string, old []model.Permission, new []model.Permission, correlationId string) (commands []PermissionCommand) {
+	commands = []PermissionCommand{}
+	current := map[string]string{}
+	for _, permission := range old {
+		current[permissionKey(permission)] = permissionRight(permission)
+	}
+	wanted := map[string]bool{}
+	for _, permission := range new {
+		wanted[permissionKey(permission)] = true
+	}
+	for _, permission := range old {
+		if !wanted[permissionKey(permission)] {
+			commands = append(commands, PermissionCommand{Command: "DELETE", Kind: kind, Resource: id, User: permission.User, Group: permission.Role, CorrelationId: correlationId})
+		}
+	}
+	for _, permission := range new {
+		right := permissionRight(permission)
+		if existing, ok := current[permissionKey(permission)]; ok && existing == right {
+			continue
+		}
+		commands = append(commands, PermissionCommand{Command: "PUT", Kind: kind, Resource: id, User: permission.User, Group: permission.Role, Right: right, CorrelationId: correlationId})
+	}
+	return
+}
+
+//applies the command to the permissions of the auth entry
+func ApplyPermissionCommand(auth model.Auth, command PermissionCommand) (result model.Auth, err error) {
+	if command.Command != "PUT" && command.Command != "DELETE" {
+		return auth, errors.New("unknown permission command: " + command.Command)
+	}
+	permission := model.Permission{
+		User:         command.User,
+		Role:         command.Group,
+		Read:         strings.Contains(command.Right, "r"),
+		Write:        strings.Contains(command.Right, "w"),
+		Execute:      strings.Contains(command.Right, "x"),
+		Administrate: strings.Contains(command.Right, "a"),
+	}
+	if (permission.User == "") == (permission.Role == "") {
+		return auth, errors.New("permission command needs either user or group")
+	}
+	result = auth
+	result.Permissions = []model.Permission{}
+	for _, existing := range auth.Permissions {
+		if permissionKey(existing) != permissionKey(permission) {
+			result.Permissions = append(result.Permissions, existing)
+		}
+	}
+	if command.Command == "PUT" && permissionRight(permission) != "" {
+		result.Permissions = append(result.Permissions, permission)
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func Example_permissionCommands() {
+	old := []model.Permission{{User: "a", Read: true}, {User: "b", Read: true}, {Role: "user", Read: true, Execute: true}}
+	new, err := NormalizePermissions([]model.Permission{{User: "a", Read: true}, {Role: "user", Read: true}, {User: "c", Write: true}, {User: "d"}})
+	fmt.Println(printPermissions(new), err)
+	_, err = NormalizePermissions([]model.Permission{{User: "a", Role: "user", Read: true}})
+	fmt.Println(err)
+
+	commands := PermissionCommands("deviceinstance", "id", old, new, "cid")
+	for _, command := range commands {
+		fmt.Println(command.Command, "user:"+command.User, "group:"+command.Group, "right:"+command.Right)
+	}
+
+	auth := model.Auth{ResourceId: "id", Owner: "owner", Permissions: old}
+	for _, command := range commands {
+		auth, err = ApplyPermissionCommand(auth, command)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	fmt.Println(printPermissions(auth.Permissions))
+
+	//Output:
+	//[user a: r role user: r user c: w] <nil>
+	//permission needs either user or role
+	//DELETE user:b group: right:
+	//PUT user: group:user right:r
+	//PUT user:c group: right:w
+	//[user a: r role user: r user c: w]
+}
+
+func Example_administratePermission() {
+	//a unchanged round trip of the permissions keeps the administrate right
+	old := []model.Permission{{User: "a", Read: true, Write: true, Execute: true, Administrate: true}, {User: "b", Read: true}}
+	new, err := NormalizePermissions(old)
+	fmt.Println(printPermissions(new), err, len(PermissionCommands("deviceinstance", "id", old, new, "cid")))
+
+	new, _ = NormalizePermissions([]model.Permission{{User: "a", Read: true, Write: true, Execute: true, Administrate: true}, {User: "b", Read: true, Administrate: true}})
+	commands := PermissionCommands("deviceinstance", "id", old, new, "cid")
+	for _, command := range commands {
+		fmt.Println(command.Command, "user:"+command.User, "right:"+command.Right)
+	}
+	auth, err := ApplyPermissionCommand(model.Auth{ResourceId: "id", Owner: "owner", Permissions: old}, commands[0])
+	fmt.Println(printPermissions(auth.Permissions), err)
+
+	//Output:
+	//[user a: rwxa user b: r] <nil> 0
+	//PUT user:b right:ra
+	//[user a: rwxa user b: ra] <nil>
+}
+
+func printPermissions(permissions []model.Permission) (result []string) {
+	for _, permission := range permissions {
+		result = append(result, permissionKey(permission)+": "+permissionRight(permission))
+	}
+	return
+}
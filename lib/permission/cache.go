@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+type decision struct {
+	allowed bool
+	expires time.Time
+}
+
+//decisions of the permission service by resource and "<subject>/<right>"
+type decisionCache struct {
+	ttl         time.Duration
+	mux         sync.Mutex
+	resources   map[string]map[string]decision
+	lastCleanup time.Time
+}
+
+func newDecisionCache(ttl time.Duration) *decisionCache {
+	return &decisionCache{ttl: ttl, resources: map[string]map[string]decision{}, lastCleanup: time.Now()}
+}
+
+func resourceKey(kind string, id string) string {
+	return kind + "/" + id
+}
+
+func (this *decisionCache) get(subject string, kind string, id string, right string) (allowed bool, ok bool) {
+	if this.ttl <= 0 {
+		return false, false
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.resources[resourceKey(kind, id)][subject+"/"+right]
+	if !ok || time.Now().After(entry.expires) {
+		return false, false
+	}
+	return entry.allowed, true
+}
+
+func (this *decisionCache) set(subject string, kind string, id string, right string, allowed bool) {
+	if this.ttl <= 0 {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	now := time.Now()
+	if now.Sub(this.lastCleanup) > this.ttl {
+		this.cleanup(now)
+	}
+	key := resourceKey(kind, id)
+	decisions, ok := this.resources[key]
+	if !ok {
+		decisions = map[string]decision{}
+		this.resources[key] = decisions
+	}
+	decisions[subject+"/"+right] = decision{allowed: allowed, expires: now.Add(this.ttl)}
+}
+
+//removes expired decisions; expects locked mux
+func (this *decisionCache) cleanup(now time.Time) {
+	for key, decisions := range this.resources {
+		for subject, entry := range decisions {
+			if now.After(entry.expires) {
+				delete(decisions, subject)
+			}
+		}
+		if len(decisions) == 0 {
+			delete(this.resources, key)
+		}
+	}
+	this.lastCleanup = now
+}
+
+//removes the decisions of all subjects for the resource
+func (this *decisionCache) invalidate(kind string, id string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.resources, resourceKey(kind, id))
+}
+
+func (this *decisionCache) clear() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.resources = map[string]map[string]decision{}
+}
+
+//invalidates cached decisions of the resource changed by the permission command; used as consumer of util.Config.PermissionsTopic
+func HandlePermissionCommand(msg []byte) (err error) {
+	command := PermissionCommand{}
+	err = json.Unmarshal(msg, &command)
+	if err != nil {
+		log.Println("ERROR: unable to read permission command; clear permission cache", err)
+		getCache().clear()
+		return nil
+	}
+	if command.Resource == "" {
+		getCache().clear()
+		return nil
+	}
+	getCache().invalidate(command.Kind, command.Resource)
+	return nil
+}
+
+//removes cached decisions of the resource (e.g. after it is deleted)
+func Invalidate(kind string, id string) {
+	getCache().invalidate(kind, id)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+func Example_checkMultiple() {
+	requested := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		ids := []string{}
+		json.NewDecoder(r.Body).Decode(&ids)
+		sort.Strings(ids)
+		requested = append(requested, ids)
+		json.NewEncoder(res).Encode(map[string]bool{"a": true, "c": true})
+	}))
+	defer server.Close()
+	util.Config = &util.ConfigStruct{PermissionsUrl: server.URL, PermissionsCacheTTL: 60}
+	jwt := jwt_http_router.Jwt{UserId: "user"}
+
+	allowed, err := CheckMultiple(jwt, "deviceinstance", []string{"a", "b", "c", "a"}, model.EXECUTE)
+	fmt.Println(allowed, err)
+	allowed, err = CheckMultiple(jwt, "deviceinstance", []string{"a", "b", "d"}, model.EXECUTE)
+	fmt.Println(allowed, err)
+	fmt.Println(CheckBool(jwt, "deviceinstance", "c", model.EXECUTE))
+
+	fmt.Println(HandlePermissionCommand([]byte(`{"command": "PUT", "kind": "deviceinstance", "resource": "a", "user": "user", "right": "r"}`)))
+	allowed, err = CheckMultiple(jwt, "deviceinstance", []string{"a", "b"}, model.EXECUTE)
+	fmt.Println(allowed, err)
+	fmt.Println(requested)
+
+	//Output:
+	//map[a:true b:false c:true] <nil>
+	//map[a:true b:false d:false] <nil>
+	//true <nil>
+	//<nil>
+	//map[a:true b:false] <nil>
+	//[[a b c] [b d] [a b]]
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+//evaluates permissions instead of the permissionsearch service; right is one of "r", "w", "x", "a"
+type Engine interface {
+	CheckMultiple(jwt jwt_http_router.Jwt, kind string, ids []string, right string) (allowed map[string]bool, err error)
+	ListAll(jwt jwt_http_router.Jwt, kind string, right string) (ids []string, err error)
+	Search(jwt jwt_http_router.Jwt, kind string, query string, right string) (ids []string, err error)
+	SelectField(jwt jwt_http_router.Jwt, kind string, field string, value string, right string) (ids []string, err error)
+	Exists(kind string, id string) (exists bool, err error)
+	GetAuth(kind string, id string) (auth model.Auth, err error)
+}
+
+//nil uses the permissionsearch service of util.Config.PermissionsUrl
+var engine Engine
+
+func SetEngine(e Engine) {
+	engine = e
+}
+
+//evaluates model.Auth entries stored in the database
+type Embedded struct {
+	db interfaces.Persistence
+}
+
+func NewEmbedded(db interfaces.Persistence) *Embedded {
+	return &Embedded{db: db}
+}
+
+//the owner and users with the role "admin" have all rights; others need a permission with the right
+//resources without owner (e.g. not yet backfilled by BackfillAuth) are only visible to admins
+func AuthAllows(auth model.Auth, jwt jwt_http_router.Jwt, right string) bool {
+	if containsString(jwt.RealmAccess.Roles, "admin") {
+		return true
+	}
+	if auth.Owner == "" {
+		return false
+	}
+	if auth.Owner == jwt.UserId {
+		return true
+	}
+	for _, permission := range auth.Permissions {
+		if permission.User != "" && permission.User != jwt.UserId {
+			continue
+		}
+		if permission.Role != "" && !containsString(jwt.RealmAccess.Roles, permission.Role) {
+			continue
+		}
+		if permission.User == "" && permission.Role == "" {
+			continue
+		}
+		switch right {
+		case "r":
+			if permission.Read {
+				return true
+			}
+		case "w":
+			if permission.Write {
+				return true
+			}
+		case "x":
+			if permission.Execute {
+				return true
+			}
+		case "a":
+			if permission.Administrate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//auth entries of the kind are loaded at once; ids without entry of the kind are checked one by one
+func (this *Embedded) CheckMultiple(jwt jwt_http_router.Jwt, kind string, ids []string, right string) (allowed map[string]bool, err error) {
+	auths, err := this.auths(kind)
+	if err != nil {
+		return allowed, err
+	}
+	allowed = map[string]bool{}
+	for _, id := range ids {
+		if _, done := allowed[id]; done {
+			continue
+		}
+		auth, ok := auths[id]
+		if !ok {
+			auth, err = this.db.GetAuth(id)
+			if err != nil {
+				return allowed, err
+			}
+		}
+		allowed[id] = (auth.Kind == "" || auth.Kind == kind) && AuthAllows(auth, jwt, right)
+	}
+	return allowed, nil
+}
+
+//sorted by id
+func (this *Embedded) ListAll(jwt jwt_http_router.Jwt, kind string, right string) (ids []string, err error) {
+	auths, err := this.auths(kind)
+	if err != nil {
+		return ids, err
+	}
+	ids = []string{}
+	for id, auth := range auths {
+		if AuthAllows(auth, jwt, right) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+//case insensitive search in the names of the resources
+func (this *Embedded) Search(jwt jwt_http_router.Jwt, kind string, query string, right string) (ids []string, err error) {
+	candidates, err := this.ListAll(jwt, kind, right)
+	if err != nil {
+		return ids, err
+	}
+	documents, err := this.db.GetAuthDocuments(kind)
+	if err != nil {
+		return ids, err
+	}
+	query = strings.ToLower(query)
+	ids = []string{}
+	for _, id := range candidates {
+		if strings.Contains(strings.ToLower(documents[id].Name), query) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (this *Embedded) SelectField(jwt jwt_http_router.Jwt, kind string, field string, value string, right string) (ids []string, err error) {
+	candidates, err := this.ListAll(jwt, kind, right)
+	if err != nil {
+		return ids, err
+	}
+	documents, err := this.db.GetAuthDocuments(kind)
+	if err != nil {
+		return ids, err
+	}
+	ids = []string{}
+	for _, id := range candidates {
+		if containsString(documents[id].Fields[field], value) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (this *Embedded) Exists(kind string, id string) (exists bool, err error) {
+	auth, err := this.db.GetAuth(id)
+	return auth.Owner != "" && (auth.Kind == "" || auth.Kind == kind), err
+}
+
+func (this *Embedded) GetAuth(kind string, id string) (auth model.Auth, err error) {
+	auth, err = this.db.GetAuth(id)
+	if err != nil {
+		return auth, err
+	}
+	if auth.Owner == "" || (auth.Kind != "" && auth.Kind != kind) {
+		return auth, errors.New("unknown resource: " + id)
+	}
+	return auth, nil
+}
+
+//auth entries of the kind by resource id
+func (this *Embedded) auths(kind string) (result map[string]model.Auth, err error) {
+	auths, err := this.db.GetAuths(kind)
+	if err != nil {
+		return result, err
+	}
+	result = map[string]model.Auth{}
+	for _, auth := range auths {
+		result[auth.ResourceId] = auth
+	}
+	return result, nil
+}
+
+//applies limit and offset of the permissionsearch api
+func page(ids []string, limit string, offset string) (result []string, err error) {
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		return result, err
+	}
+	offsetInt, err := strconv.Atoi(offset)
+	if err != nil {
+		return result, err
+	}
+	if offsetInt >= len(ids) || limitInt <= 0 || offsetInt < 0 {
+		return []string{}, nil
+	}
+	end := offsetInt + limitInt
+	if end > len(ids) {
+		end = len(ids)
+	}
+	return ids[offsetInt:end], nil
+}
+
+func toIdWrapper(ids []string) (result []IdWrapper) {
+	result = []IdWrapper{}
+	for _, id := range ids {
+		result = append(result, IdWrapper{Id: id})
+	}
+	return
+}
+
+func containsString(list []string, element string) bool {
+	for _, e := range list {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package permission
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/interfaces"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/jwt-http-router"
+)
+
+type authDbMock struct {
+	interfaces.Persistence
+	auths map[string]model.Auth
+}
+
+func (this authDbMock) GetAuth(id string) (auth model.Auth, err error) {
+	auth, ok := this.auths[id]
+	if !ok {
+		auth.ResourceId = id
+	}
+	return auth, nil
+}
+
+func (this authDbMock) GetAuths(kind string) (auths []model.Auth, err error) {
+	for _, auth := range this.auths {
+		if auth.Kind == kind {
+			auths = append(auths, auth)
+		}
+	}
+	return
+}
+
+func (this authDbMock) GetAuthDocuments(kind string) (documents map[string]model.AuthDocument, err error) {
+	documents = map[string]model.AuthDocument{}
+	for id, auth := range this.auths {
+		if auth.Kind == kind {
+			documents[id] = model.AuthDocument{Name: "name of " + id, Fields: map[string][]string{"owner": {auth.Owner}}}
+		}
+	}
+	return
+}
+
+func Example_embedded() {
+	util.Config = &util.ConfigStruct{DeviceInstanceTopic: "deviceinstance", GatewayTopic: "gateway"}
+	SetEngine(NewEmbedded(authDbMock{auths: map[string]model.Auth{
+		"d1": {ResourceId: "d1", Kind: "deviceinstance", Owner: "owner", Permissions: []model.Permission{{User: "user", Read: true}}},
+		"d2": {ResourceId: "d2", Kind: "deviceinstance", Owner: "owner", Permissions: []model.Permission{{Role: "user", Read: true, Execute: true}}},
+		"d3": {ResourceId: "d3", Kind: "deviceinstance", Owner: "user"},
+		"d4": {ResourceId: "d4", Kind: "deviceinstance"},
+		"g1": {ResourceId: "g1", Kind: "gateway", Owner: "owner"},
+	}}))
+	defer SetEngine(nil)
+	user := jwt_http_router.Jwt{UserId: "user"}
+	userWithRole := jwt_http_router.Jwt{UserId: "user", RealmAccess: jwt_http_router.Resource{Roles: []string{"user"}}}
+	admin := jwt_http_router.Jwt{UserId: "admin", RealmAccess: jwt_http_router.Resource{Roles: []string{"admin"}}}
+
+	fmt.Println(CheckMultiple(user, "deviceinstance", []string{"d1", "d2", "d3", "g1", "unknown"}, model.READ))
+	fmt.Println(CheckMultiple(userWithRole, "deviceinstance", []string{"d1", "d2", "d3"}, model.EXECUTE))
+	fmt.Println(Check(user, "deviceinstance", "d1", model.ADMINISTRATE))
+	fmt.Println(Check(user, "deviceinstance", "d3", model.ADMINISTRATE))
+	fmt.Println(ListAll(userWithRole, "deviceinstance", model.READ))
+	fmt.Println(List(admin, "deviceinstance", model.WRITE, "2", "1"))
+	fmt.Println(Exists(user, "gateway", "g1"))
+	fmt.Println(Exists(user, "gateway", "d1"))
+
+	//resources without owner are only visible to admins
+	fmt.Println(CheckMultiple(admin, "deviceinstance", []string{"d4"}, model.READ))
+	fmt.Println(CheckMultiple(user, "deviceinstance", []string{"d4"}, model.READ))
+	fmt.Println(Search(userWithRole, "deviceinstance", "OF D2", model.READ, "10", "0"))
+	fmt.Println(SelectFieldAll(user, "deviceinstance", "owner", "user", model.READ))
+
+	//Output:
+	//map[d1:true d2:false d3:true g1:false unknown:false] <nil>
+	//map[d1:false d2:true d3:true] <nil>
+	//access denied
+	//<nil>
+	//[{d1} {d2} {d3}] <nil>
+	//[{d2} {d3}] <nil>
+	//true <nil>
+	//false <nil>
+	//map[d4:true] <nil>
+	//map[d4:false] <nil>
+	//[{d2}] <nil>
+	//[{d3}] <nil>
+}
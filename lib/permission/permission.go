@@ -29,6 +29,10 @@ import (
 
 	"log"
 
+	"sync"
+
+	"time"
+
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 	"github.com/SmartEnergyPlatform/jwt-http-router"
@@ -52,30 +56,96 @@ type IdWrapper struct {
 	Id string `json:"id"`
 }
 
+var cache *decisionCache
+var cacheOnce sync.Once
+
+//decisions are cached for util.Config.PermissionsCacheTTL seconds per jwt subject
+func getCache() *decisionCache {
+	cacheOnce.Do(func() {
+		cache = newDecisionCache(time.Duration(util.Config.PermissionsCacheTTL) * time.Second)
+	})
+	return cache
+}
+
 func Check(jwt jwt_http_router.Jwt, kind string, id string, action model.AuthAction) (err error) {
-	right := authActionToString(action)
-	result := false
-	err = jwt.Impersonate.GetJSON(util.Config.PermissionsUrl+"/jwt/check/"+url.QueryEscape(kind)+"/"+url.QueryEscape(id)+"/"+right+"/bool", &result)
+	allowed, err := CheckBool(jwt, kind, id, action)
 	if err != nil {
-		log.Println("DEBUG: permissions.Check:", err)
 		return err
 	}
-	if !result {
+	if !allowed {
 		err = errors.New("access denied")
 	}
 	return
 }
 
 func CheckBool(jwt jwt_http_router.Jwt, kind string, id string, action model.AuthAction) (allowed bool, err error) {
+	if engine != nil {
+		result, err := engine.CheckMultiple(jwt, kind, []string{id}, authActionToString(action))
+		return result[id], err
+	}
 	right := authActionToString(action)
+	if jwt.UserId != "" {
+		if allowed, ok := getCache().get(jwt.UserId, kind, id, right); ok {
+			return allowed, nil
+		}
+	}
 	err = jwt.Impersonate.GetJSON(util.Config.PermissionsUrl+"/jwt/check/"+url.QueryEscape(kind)+"/"+url.QueryEscape(id)+"/"+right+"/bool", &allowed)
 	if err != nil {
 		log.Println("DEBUG: permissions.Check:", err)
+		return
+	}
+	//denials are not cached: the permission service may not yet know new resources
+	if jwt.UserId != "" && allowed {
+		getCache().set(jwt.UserId, kind, id, right, allowed)
 	}
 	return
 }
 
+//checks all ids with one request to the permission service; ids with cached (positive) decisions are not requested
+func CheckMultiple(jwt jwt_http_router.Jwt, kind string, ids []string, action model.AuthAction) (allowed map[string]bool, err error) {
+	if engine != nil {
+		return engine.CheckMultiple(jwt, kind, ids, authActionToString(action))
+	}
+	right := authActionToString(action)
+	allowed = map[string]bool{}
+	missing := []string{}
+	for _, id := range ids {
+		if _, done := allowed[id]; done {
+			continue
+		}
+		if jwt.UserId != "" {
+			if result, ok := getCache().get(jwt.UserId, kind, id, right); ok {
+				allowed[id] = result
+				continue
+			}
+		}
+		allowed[id] = false
+		missing = append(missing, id)
+	}
+	if len(missing) == 0 {
+		return allowed, nil
+	}
+	//"/ids/check/:resource_kind/:right"
+	result := map[string]bool{}
+	err = jwt.Impersonate.PostJSON(util.Config.PermissionsUrl+"/ids/check/"+url.QueryEscape(kind)+"/"+right, missing, &result)
+	if err != nil {
+		log.Println("DEBUG: permissions.CheckMultiple:", err)
+		return allowed, err
+	}
+	for _, id := range missing {
+		allowed[id] = result[id]
+		if jwt.UserId != "" && result[id] {
+			getCache().set(jwt.UserId, kind, id, right, true)
+		}
+	}
+	return allowed, nil
+}
+
 func ListAll(jwt jwt_http_router.Jwt, kind string, action model.AuthAction) (result []IdWrapper, err error) {
+	if engine != nil {
+		ids, err := engine.ListAll(jwt, kind, authActionToString(action))
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/list/:resource_kind/:right"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/list/" + url.QueryEscape(kind) + "/" + right)
@@ -91,6 +161,14 @@ func ListAll(jwt jwt_http_router.Jwt, kind string, action model.AuthAction) (res
 }
 
 func List(jwt jwt_http_router.Jwt, kind string, action model.AuthAction, limit string, offset string) (result []IdWrapper, err error) {
+	if engine != nil {
+		ids, err := engine.ListAll(jwt, kind, authActionToString(action))
+		if err != nil {
+			return result, err
+		}
+		ids, err = page(ids, limit, offset)
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/list/:resource_kind/:right"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/list/" + url.QueryEscape(kind) + "/" + right + "/" + limit + "/" + offset)
@@ -106,6 +184,14 @@ func List(jwt jwt_http_router.Jwt, kind string, action model.AuthAction, limit s
 }
 
 func Search(jwt jwt_http_router.Jwt, kind string, query string, action model.AuthAction, limit string, offset string) (result []IdWrapper, err error) {
+	if engine != nil {
+		ids, err := engine.Search(jwt, kind, query, authActionToString(action))
+		if err != nil {
+			return result, err
+		}
+		ids, err = page(ids, limit, offset)
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/search/:resource_kind/:query/:right/:limit/:offset"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/search/" + url.QueryEscape(kind) + "/" + url.QueryEscape(query) + "/" + right + "/" + limit + "/" + offset)
@@ -121,6 +207,10 @@ func Search(jwt jwt_http_router.Jwt, kind string, query string, action model.Aut
 }
 
 func SearchAll(jwt jwt_http_router.Jwt, kind string, query string, action model.AuthAction) (result []IdWrapper, err error) {
+	if engine != nil {
+		ids, err := engine.Search(jwt, kind, query, authActionToString(action))
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/search/:resource_kind/:query/:right"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/search/" + url.QueryEscape(kind) + "/" + url.QueryEscape(query) + "/" + right)
@@ -136,6 +226,10 @@ func SearchAll(jwt jwt_http_router.Jwt, kind string, query string, action model.
 }
 
 func SelectFieldAll(jwt jwt_http_router.Jwt, kind string, field string, value string, action model.AuthAction) (result []IdWrapper, err error) {
+	if engine != nil {
+		ids, err := engine.SelectField(jwt, kind, field, value, authActionToString(action))
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/select/:resource_kind/:field/:value/:right"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/select/" + url.QueryEscape(kind) + "/" + url.QueryEscape(field) + "/" + url.QueryEscape(value) + "/" + right)
@@ -151,6 +245,15 @@ func SelectFieldAll(jwt jwt_http_router.Jwt, kind string, field string, value st
 }
 
 func SelectField(jwt jwt_http_router.Jwt, kind string, field string, value string, action model.AuthAction, limit string, offset string, sortby string, sortdirection string) (result []IdWrapper, err error) {
+	if engine != nil {
+		//sorted by id
+		ids, err := engine.SelectField(jwt, kind, field, value, authActionToString(action))
+		if err != nil {
+			return result, err
+		}
+		ids, err = page(ids, limit, offset)
+		return toIdWrapper(ids), err
+	}
 	//"/jwt/select/:resource_kind/:field/:value/:right"
 	right := authActionToString(action)
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/jwt/select/" + url.QueryEscape(kind) + "/" + url.QueryEscape(field) + "/" + url.QueryEscape(value) + "/" + right + "/" + limit + "/" + offset + "/" + sortby + "/" + sortdirection)
@@ -166,6 +269,9 @@ func SelectField(jwt jwt_http_router.Jwt, kind string, field string, value strin
 }
 
 func Exists(jwt jwt_http_router.Jwt, kind string, id string) (exists bool, err error) {
+	if engine != nil {
+		return engine.Exists(kind, id)
+	}
 	// /administrate/exists/:resource_kind/:resource
 	resp, err := jwt.Impersonate.Get(util.Config.PermissionsUrl + "/administrate/exists/" + url.QueryEscape(kind) + "/" + url.QueryEscape(id))
 	if err != nil {
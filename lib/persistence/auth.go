@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+)
+
+//auth entries share the id of their resource; auth.Owner is empty if the resource has no auth entry
+func (this *Persistence) GetAuth(id string) (auth model.Auth, err error) {
+	auth.ResourceId = id
+	err = this.ordf.SelectLevel(&auth, -1)
+	return
+}
+
+//replaces the auth entry of auth.ResourceId
+func (this *Persistence) SetAuth(auth model.Auth) (err error) {
+	if auth.ResourceId == "" || auth.Owner == "" {
+		return errors.New("auth needs resource_id and owner")
+	}
+	old, err := this.GetAuth(auth.ResourceId)
+	if err != nil {
+		return err
+	}
+	for index := range auth.Permissions {
+		auth.Permissions[index].Id = ""
+	}
+	err = this.ordf.SetIdDeep(&auth)
+	if err != nil {
+		return err
+	}
+	if old.Owner == "" {
+		_, err = this.ordf.Insert(auth)
+		return err
+	}
+	_, err = this.ordf.Update(old, auth)
+	return err
+}
+
+func (this *Persistence) DeleteAuth(id string) (err error) {
+	auth, err := this.GetAuth(id)
+	if err != nil {
+		return err
+	}
+	if auth.Owner == "" {
+		return nil
+	}
+	_, err = this.ordf.Delete(auth)
+	return err
+}
+
+//all auth entries of the kind with their permissions; one query for the entries and one for the permissions
+func (this *Persistence) GetAuths(kind string) (auths []model.Auth, err error) {
+	auths = []model.Auth{}
+	err = this.ordf.SearchAll(&auths, model.Auth{Kind: kind})
+	if err != nil {
+		return auths, err
+	}
+	permissions := []model.Permission{}
+	err = this.ordf.SearchAll(&permissions, model.Permission{})
+	if err != nil {
+		return auths, err
+	}
+	index := map[string]model.Permission{}
+	for _, permission := range permissions {
+		index[permission.Id] = permission
+	}
+	for i := range auths {
+		for j, permission := range auths[i].Permissions {
+			if loaded, ok := index[permission.Id]; ok {
+				auths[i].Permissions[j] = loaded
+			}
+		}
+	}
+	return auths, nil
+}
+
+//names and search fields of all resources of the kind (topic name) in one query
+func (this *Persistence) GetAuthDocuments(kind string) (documents map[string]model.AuthDocument, err error) {
+	documents = map[string]model.AuthDocument{}
+	switch kind {
+	case util.Config.DeviceTypeTopic:
+		deviceTypes := []model.DeviceType{}
+		err = this.ordf.SearchAll(&deviceTypes, model.DeviceType{})
+		for _, dt := range deviceTypes {
+			services := []string{}
+			for _, service := range dt.Services {
+				services = append(services, service.Id)
+			}
+			documents[dt.Id] = model.AuthDocument{Name: dt.Name, Fields: map[string][]string{
+				util.Config.DeviceTypeServiceFieldSearchName:     services,
+				util.Config.DeviceTypeMaintenanceFieldSearchName: dt.Maintenance,
+			}}
+		}
+	case util.Config.DeviceInstanceTopic:
+		instances := []model.DeviceInstance{}
+		err = this.ordf.SearchAll(&instances, model.DeviceInstance{})
+		for _, instance := range instances {
+			documents[instance.Id] = model.AuthDocument{Name: instance.Name, Fields: map[string][]string{
+				util.Config.DeviceInstanceDtFieldSearchName:  {instance.DeviceType},
+				util.Config.DeviceInstanceUrlFieldSearchName: {instance.Url},
+			}}
+		}
+	case util.Config.GatewayTopic:
+		gateways := []model.GatewayName{}
+		err = this.ordf.SearchAll(&gateways, model.GatewayName{})
+		for _, gateway := range gateways {
+			documents[gateway.Id] = model.AuthDocument{Name: gateway.Name, Fields: map[string][]string{}}
+		}
+	case util.Config.ValueTypeTopic:
+		valueTypes := []model.ValueType{}
+		err = this.ordf.SearchAll(&valueTypes, model.ValueType{})
+		for _, vt := range valueTypes {
+			documents[vt.Id] = model.AuthDocument{Name: vt.Name, Fields: map[string][]string{}}
+		}
+	}
+	return documents, err
+}
+
+//creates auth entries owned by owner for resources without auth entry (e.g. created before the embedded engine was used)
+func (this *Persistence) BackfillAuth(owner string) (count int, err error) {
+	if owner == "" {
+		return 0, errors.New("missing owner")
+	}
+	kinds := map[string]string{
+		util.Config.DeviceTypeTopic:     model.KindDeviceType,
+		util.Config.DeviceInstanceTopic: model.KindDeviceInstance,
+		util.Config.GatewayTopic:        model.KindGateway,
+		util.Config.ValueTypeTopic:      model.KindValueType,
+	}
+	for kind, flushKind := range kinds {
+		ids, err := this.GetFlushIds(flushKind)
+		if err != nil {
+			return count, err
+		}
+		auths, err := this.GetAuths(kind)
+		if err != nil {
+			return count, err
+		}
+		known := map[string]bool{}
+		for _, auth := range auths {
+			known[auth.ResourceId] = true
+		}
+		for _, id := range ids {
+			if known[id] {
+				continue
+			}
+			//entries without kind are not found by GetAuths
+			auth, err := this.GetAuth(id)
+			if err != nil {
+				return count, err
+			}
+			if auth.Owner != "" {
+				continue
+			}
+			err = this.SetAuth(model.Auth{ResourceId: id, Kind: kind, Owner: owner})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
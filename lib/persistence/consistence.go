@@ -20,6 +20,7 @@ import (
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/format"
 	"log"
 	"reflect"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
@@ -51,6 +52,12 @@ func (this *Persistence) ValueTypeIsConsistent(valueType model.ValueType) (err e
 		if valueType.BaseType == "" {
 			return errors.New("missing base type")
 		}
+		if valueType.Extends != "" {
+			err = this.valueTypeExtensionIsConsistent(valueType)
+			if err != nil {
+				return err
+			}
+		}
 		if len(valueType.Fields) != 1 && (valueType.BaseType == model.ListBaseType || valueType.BaseType == model.MapBaseType) {
 			return errors.New("Collection BaseType with more or less than one field")
 		}
@@ -78,6 +85,65 @@ func (this *Persistence) ValueTypeIsConsistent(valueType model.ValueType) (err e
 	return nil
 }
 
+func (this *Persistence) valueTypeExtensionIsConsistent(valueType model.ValueType) (err error) {
+	if valueType.BaseType != model.StructBaseType && valueType.BaseType != model.IndexStructBaseType {
+		return errors.New("only structures may extend value types")
+	}
+	err = this.valueTypeExtensionIsAcyclic(valueType)
+	if err != nil {
+		return err
+	}
+	parent, err := this.GetValueTypeById(valueType.Extends)
+	if err != nil {
+		log.Println(err)
+		return errors.New("error on extended value type check")
+	}
+	if parent.Name == "" {
+		return errors.New("unknown extended valuetype id is used")
+	}
+	if parent.BaseType != valueType.BaseType {
+		return errors.New("extended valuetype has different base type")
+	}
+	inherited := map[string]bool{}
+	for _, field := range parent.Fields {
+		inherited[field.Name] = true
+	}
+	for _, field := range valueType.Fields {
+		if inherited[field.Name] {
+			return errors.New("field '" + field.Name + "' is already inherited from extended valuetype")
+		}
+	}
+	return nil
+}
+
+//the extended value types would contain a cycle
+type valueTypeExtensionCycleError string
+
+func (this valueTypeExtensionCycleError) Error() string {
+	return string(this)
+}
+
+//follows the stored chain of extended value types; it may neither lead back to the value type nor contain another cycle
+func (this *Persistence) valueTypeExtensionIsAcyclic(valueType model.ValueType) (err error) {
+	chain := []string{}
+	if valueType.Id != "" {
+		chain = append(chain, valueType.Id)
+	}
+	for extends := valueType.Extends; extends != ""; {
+		if contains(chain, extends) {
+			return valueTypeExtensionCycleError("cyclic value type inheritance: " + strings.Join(append(chain, extends), " extends "))
+		}
+		chain = append(chain, extends)
+		parent, err := this.getValueTypeDeclaration(extends)
+		if err != nil {
+			log.Println(err)
+			return errors.New("error on extended value type check")
+		}
+		extends = parent.Extends
+	}
+	return nil
+}
+
 func (this *Persistence) DeviceTypeIsConsistent(deviceType model.DeviceType) (ok bool, inconsistencies string) {
 	if deviceType.Name == "" {
 		return false, "missing name"
@@ -101,6 +167,12 @@ func (this *Persistence) DeviceTypeIsConsistent(deviceType model.DeviceType) (ok
 		if !ok {
 			return
 		}
+		for _, format := range service.GetEndpointFormats() {
+			err := validateEndpointFormat(format.Format, deviceType.Config)
+			if err != nil {
+				return false, "invalid endpoint format '" + format.Format + "' of service '" + service.Name + "': " + err.Error()
+			}
+		}
 
 		serviceType := model.SmartObject{Id: service.ServiceType}
 		isServiceTypeId, err := this.ordf.IdIsOfClass(serviceType)
@@ -192,16 +264,21 @@ func (this *Persistence) DeviceInstanceIsConsistent(deviceInstance model.DeviceI
 	}
 
 	for _, service := range deviceType.Services {
-		endpoint := createEndpointString(service.EndpointFormat, deviceInstance.Url, service.Url, deviceInstance.Config)
-		if endpoint != "" {
-			collisions, err := this.EndpointCollision(endpoint)
+		for _, format := range service.GetEndpointFormats() {
+			endpoint, err := createEndpointString(format.Format, deviceInstance.Url, service.Url, deviceInstance.Config)
 			if err != nil {
-				return false, "error: " + err.Error()
+				return false, "invalid endpoint for service '" + service.Name + "': " + err.Error()
 			}
-			for _, collision := range collisions {
-				if collision.Device != deviceInstance.Id {
-					log.Println("WARNING: endpoint collision with ", collision)
-					return false, "error: endpoint collision"
+			if endpoint != "" {
+				collisions, err := this.EndpointCollision(endpoint)
+				if err != nil {
+					return false, "error: " + err.Error()
+				}
+				for _, collision := range collisions {
+					if collision.Device != deviceInstance.Id {
+						log.Println("WARNING: endpoint collision with ", collision)
+						return false, "error: endpoint collision"
+					}
 				}
 			}
 		}
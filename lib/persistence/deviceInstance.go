@@ -60,7 +60,7 @@ func (this *Persistence) SetDeviceInstance(deviceInstance model.DeviceInstance)
 			return err
 		}
 		gw.Hash = ""
-		err = eventsourcing.PublishGateway(gw, "")
+		_, err = eventsourcing.PublishGateway(gw, "")
 		if err != nil {
 			return err
 		}
@@ -116,7 +116,7 @@ func (this *Persistence) DeleteDeviceInstance(id string) (err error) {
 		}
 		gw.Devices = []model.DeviceInstance{}
 		gw.Hash = ""
-		err = eventsourcing.PublishGateway(gw, "")
+		_, err = eventsourcing.PublishGateway(gw, "")
 		if gw.Name == "" {
 			return err
 		}
@@ -57,7 +57,17 @@ func (this *Persistence) DeviceTypeIdExists(id string) (exists bool) {
 	return
 }
 
+//updates of generated device types are restricted to name, description and maintenance
 func (this *Persistence) SetDeviceType(deviceType model.DeviceType) (err error) {
+	return this.setDeviceType(deviceType, false)
+}
+
+//stores a generated device type accepted by a review (see GetAcceptedDeviceType)
+func (this *Persistence) SetReviewedDeviceType(deviceType model.DeviceType) (err error) {
+	return this.setDeviceType(deviceType, true)
+}
+
+func (this *Persistence) setDeviceType(deviceType model.DeviceType, reviewed bool) (err error) {
 	old, err := this.GetDeepDeviceTypeById(deviceType.Id)
 	if err != nil {
 		return err
@@ -71,6 +81,21 @@ func (this *Persistence) SetDeviceType(deviceType model.DeviceType) (err error)
 		temp.Name = deviceType.Name
 		temp.Description = deviceType.Description
 		temp.Maintenance = deviceType.Maintenance
+		if reviewed {
+			temp.Generated = false
+			temp.Vendor = deviceType.Vendor
+			temp.DeviceClass = deviceType.DeviceClass
+			//temp shares the services of old; renames need an own copy
+			renamed, err := this.GetDeepDeviceTypeById(old.Id)
+			if err != nil {
+				return err
+			}
+			_, err = renameDeviceTypeFields(&renamed, deviceTypeFieldNames(deviceType))
+			if err != nil {
+				return err
+			}
+			temp.Services = renamed.Services
+		}
 		deviceType = temp
 	}
 	_, err = this.ordf.Update(old, deviceType)
@@ -91,7 +116,7 @@ func (this *Persistence) SetDeviceType(deviceType model.DeviceType) (err error)
 		for _, instance := range deviceInstances {
 			if instance.ImgUrl == "" || instance.ImgUrl == old.ImgUrl {
 				instance.ImgUrl = deviceType.ImgUrl
-				err = eventsourcing.PublishDeviceInstance(instance, "")
+				_, err = eventsourcing.PublishDeviceInstance(instance, "")
 				if err != nil {
 					return
 				}
@@ -20,8 +20,7 @@ import (
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 
 	"errors"
-
-	"github.com/cbroglie/mustache"
+	"sort"
 )
 
 func (this *Persistence) UpdateDeviceEndpoints(device model.DeviceInstance) error {
@@ -40,24 +39,37 @@ func (this *Persistence) UpdateDeviceEndpoints(device model.DeviceInstance) erro
 		if err != nil {
 			return err
 		}
+		this.endpointPatterns.invalidate(endpoint)
 	}
 
 	//create new
 	for _, service := range deviceType.Services {
-		endpoint := model.Endpoint{
-			ProtocolHandler: service.Protocol.ProtocolHandlerUrl,
-			Service:         service.Id,
-			Device:          device.Id,
-			Endpoint:        createEndpointString(service.EndpointFormat, device.Url, service.Url, device.Config),
-		}
-		if endpoint.Endpoint != "" {
-			tempErr := this.ordf.SetIdDeep(&endpoint)
+		for _, format := range service.GetEndpointFormats() {
+			endpointString, tempErr := createEndpointString(format.Format, device.Url, service.Url, device.Config)
 			if tempErr != nil {
 				err = tempErr
-			} else {
-				_, tempErr = this.ordf.Insert(endpoint)
+				continue
+			}
+			endpoint := model.Endpoint{
+				ProtocolHandler: format.ProtocolHandler,
+				Role:            format.Role,
+				Service:         service.Id,
+				ServiceType:     service.ServiceType,
+				Protocol:        service.Protocol.Id,
+				Device:          device.Id,
+				Endpoint:        endpointString,
+			}
+			endpoint.Pattern = model.IsEndpointPattern(endpoint.Endpoint)
+			if endpoint.Endpoint != "" {
+				tempErr := this.ordf.SetIdDeep(&endpoint)
 				if tempErr != nil {
 					err = tempErr
+				} else {
+					_, tempErr = this.ordf.Insert(endpoint)
+					if tempErr != nil {
+						err = tempErr
+					}
+					this.endpointPatterns.invalidate(endpoint)
 				}
 			}
 		}
@@ -75,19 +87,11 @@ func (this *Persistence) DeleteEndpoints(deviceid string) (err error) {
 		if err != nil {
 			return err
 		}
+		this.endpointPatterns.invalidate(endpoint)
 	}
 	return
 }
 
-func createEndpointString(format string, device string, service string, config []model.ConfigField) (result string) {
-	conf := map[string]string{"device_uri": device, "service_uri": service}
-	for _, field := range config {
-		conf[field.Name] = field.Value
-	}
-	result, _ = mustache.Render(format, conf)
-	return
-}
-
 func (this *Persistence) UpdateDeviceTypeEndpoints(deviceType model.DeviceType) error {
 	devices, err := this.GetAllDeviceInstanceUsingDeviceTypes(deviceType.Id)
 	if err != nil {
@@ -106,20 +110,136 @@ func (this *Persistence) UpdateDeviceTypeEndpoints(deviceType model.DeviceType)
 	return nil
 }
 
+//exact matches take precedence; patterns of the protocol handler are only evaluated if none exists
 func (this *Persistence) GetEndpoints(endpoint string, protocolHandler string) (result []model.Endpoint, err error) {
 	err = this.ordf.SearchAll(&result, model.Endpoint{Endpoint: endpoint, ProtocolHandler: protocolHandler})
+	if err != nil {
+		return result, err
+	}
+	if len(result) == 0 {
+		patterns, err := this.getEndpointPatterns(protocolHandler)
+		if err != nil {
+			return result, err
+		}
+		result = model.ResolveEndpointPatterns(endpoint, patterns)
+	}
+	err = this.completeEndpoints(result, map[string]model.Service{})
 	return
 }
 
-func (this *Persistence) GetEndpointByDeviceAndService(deviceId string, serviceId string) (result model.Endpoint, err error) {
+//cached for util.Config.EndpointPatternCacheTTL seconds
+func (this *Persistence) getEndpointPatterns(protocolHandler string) (result []model.Endpoint, err error) {
+	return this.endpointPatterns.get(protocolHandler)
+}
+
+func (this *Persistence) loadEndpointPatterns(protocolHandler string) (result []model.Endpoint, err error) {
+	err = this.ordf.SearchAll(&result, model.Endpoint{ProtocolHandler: protocolHandler, Pattern: true})
+	return
+}
+
+const endpointBatchSize = 100
+
+//endpoints of the protocol handler matching one of the endpoint strings; one query per endpointBatchSize endpoint strings
+func (this *Persistence) GetEndpointsByList(endpoints []string, protocolHandler string) (result []model.Endpoint, err error) {
+	result = []model.Endpoint{}
+	services := map[string]model.Service{}
+	var patterns []model.Endpoint
+	for start := 0; start < len(endpoints); start = start + endpointBatchSize {
+		end := start + endpointBatchSize
+		if end > len(endpoints) {
+			end = len(endpoints)
+		}
+		variants := []interface{}{}
+		for _, endpoint := range endpoints[start:end] {
+			variants = append(variants, model.Endpoint{Endpoint: endpoint})
+		}
+		batch := []model.Endpoint{}
+		err = this.ordf.VariationSearchAll(&batch, model.Endpoint{ProtocolHandler: protocolHandler}, variants...)
+		if err != nil {
+			return result, err
+		}
+		batch, err = this.resolveUnmatchedEndpoints(batch, endpoints[start:end], protocolHandler, &patterns)
+		if err != nil {
+			return result, err
+		}
+		err = this.completeEndpoints(batch, services)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, batch...)
+	}
+	return result, nil
+}
+
+//adds pattern matches for endpoint strings without exact match; patterns are loaded once on first use
+func (this *Persistence) resolveUnmatchedEndpoints(matches []model.Endpoint, endpoints []string, protocolHandler string, patterns *[]model.Endpoint) (result []model.Endpoint, err error) {
+	result = matches
+	matched := map[string]bool{}
+	for _, endpoint := range matches {
+		matched[endpoint.Endpoint] = true
+	}
+	for _, endpoint := range endpoints {
+		if matched[endpoint] {
+			continue
+		}
+		matched[endpoint] = true
+		if *patterns == nil {
+			*patterns, err = this.getEndpointPatterns(protocolHandler)
+			if err != nil {
+				return result, err
+			}
+			if *patterns == nil {
+				*patterns = []model.Endpoint{}
+			}
+		}
+		result = append(result, model.ResolveEndpointPatterns(endpoint, *patterns)...)
+	}
+	return result, nil
+}
+
+//endpoints stored before service type and protocol were copied get them from their service; services are cached by id
+//updating the device instance (e.g. by POST /admin/flush) stores them
+func (this *Persistence) completeEndpoints(endpoints []model.Endpoint, services map[string]model.Service) (err error) {
+	for index, endpoint := range endpoints {
+		if endpoint.ServiceType != "" {
+			continue
+		}
+		service, ok := services[endpoint.Service]
+		if !ok {
+			service, err = this.GetServiceById(endpoint.Service)
+			if err != nil {
+				return err
+			}
+			services[endpoint.Service] = service
+		}
+		endpoints[index].ServiceType = service.ServiceType
+		endpoints[index].Protocol = service.Protocol.Id
+	}
+	return nil
+}
+
+//protocolHandler and role are optional; without role the endpoint without role is preferred
+func (this *Persistence) GetEndpointByDeviceAndService(deviceId string, serviceId string, protocolHandler string, role string) (result model.Endpoint, err error) {
 	list := []model.Endpoint{}
-	err = this.ordf.Search(&list, model.Endpoint{Device: deviceId, Service: serviceId}, 1, 0)
+	err = this.ordf.SearchAll(&list, model.Endpoint{Device: deviceId, Service: serviceId, ProtocolHandler: protocolHandler, Role: role})
 	if err != nil {
 		return result, err
 	}
 	if len(list) == 0 {
+		if role != "" {
+			return result, errors.New("no endpoint with given ids and role found")
+		}
 		return result, errors.New("no endpoint with given ids found")
 	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Role != list[j].Role {
+			return list[i].Role < list[j].Role
+		}
+		if list[i].ProtocolHandler != list[j].ProtocolHandler {
+			return list[i].ProtocolHandler < list[j].ProtocolHandler
+		}
+		return list[i].Endpoint < list[j].Endpoint
+	})
 	return list[0], err
 }
 
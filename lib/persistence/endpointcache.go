@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+type endpointPatternEntry struct {
+	patterns []model.Endpoint
+	expires  time.Time
+}
+
+//pattern endpoints by protocol handler
+//entries are dropped when this instance changes pattern endpoints of the handler and expire after ttl (changes of other instances)
+type endpointPatternCache struct {
+	ttl        time.Duration
+	load       func(protocolHandler string) ([]model.Endpoint, error)
+	mux        sync.Mutex
+	entries    map[string]endpointPatternEntry
+	generation int64 //incremented on invalidation; results loaded before are not stored
+}
+
+func newEndpointPatternCache(ttl time.Duration, load func(protocolHandler string) ([]model.Endpoint, error)) *endpointPatternCache {
+	return &endpointPatternCache{ttl: ttl, load: load, entries: map[string]endpointPatternEntry{}}
+}
+
+//the result is shared and must not be modified
+func (this *endpointPatternCache) get(protocolHandler string) (patterns []model.Endpoint, err error) {
+	if this.ttl <= 0 {
+		return this.load(protocolHandler)
+	}
+	this.mux.Lock()
+	entry, ok := this.entries[protocolHandler]
+	generation := this.generation
+	this.mux.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.patterns, nil
+	}
+	patterns, err = this.load(protocolHandler)
+	if err != nil {
+		return patterns, err
+	}
+	if patterns == nil {
+		patterns = []model.Endpoint{}
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if generation == this.generation {
+		this.entries[protocolHandler] = endpointPatternEntry{patterns: patterns, expires: time.Now().Add(this.ttl)}
+	}
+	return patterns, nil
+}
+
+//has to be called after the endpoint is stored or deleted
+func (this *endpointPatternCache) invalidate(endpoint model.Endpoint) {
+	if !model.IsEndpointPattern(endpoint.Endpoint) {
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.generation++
+	delete(this.entries, endpoint.ProtocolHandler)
+}
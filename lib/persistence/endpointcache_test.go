@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func Example_endpointPatternCache() {
+	loads := map[string]int{}
+	patterns := map[string][]model.Endpoint{"mqtt": {{Id: "p1", Endpoint: "sensors/+/state", ProtocolHandler: "mqtt"}}}
+	cache := newEndpointPatternCache(time.Minute, func(protocolHandler string) ([]model.Endpoint, error) {
+		loads[protocolHandler]++
+		if protocolHandler == "broken" {
+			return nil, errors.New("unavailable")
+		}
+		return patterns[protocolHandler], nil
+	})
+
+	result, err := cache.get("mqtt")
+	fmt.Println(len(result), err, loads["mqtt"])
+	result, err = cache.get("mqtt")
+	fmt.Println(len(result), err, loads["mqtt"])
+
+	//handlers without patterns are cached too
+	result, err = cache.get("http")
+	result, err = cache.get("http")
+	fmt.Println(len(result), result != nil, err, loads["http"])
+
+	//errors are not cached
+	_, err = cache.get("broken")
+	_, err = cache.get("broken")
+	fmt.Println(err, loads["broken"])
+
+	//endpoints without pattern do not invalidate
+	cache.invalidate(model.Endpoint{Endpoint: "sensors/dev1/state", ProtocolHandler: "mqtt"})
+	result, err = cache.get("mqtt")
+	fmt.Println(len(result), err, loads["mqtt"])
+
+	patterns["mqtt"] = append(patterns["mqtt"], model.Endpoint{Id: "p2", Endpoint: "sensors/#", ProtocolHandler: "mqtt"})
+	cache.invalidate(model.Endpoint{Endpoint: "sensors/#", ProtocolHandler: "mqtt"})
+	result, err = cache.get("mqtt")
+	fmt.Println(len(result), err, loads["mqtt"])
+	result, err = cache.get("http")
+	fmt.Println(len(result), err, loads["http"])
+
+	//disabled cache
+	uncached := newEndpointPatternCache(0, func(protocolHandler string) ([]model.Endpoint, error) {
+		loads[protocolHandler]++
+		return patterns[protocolHandler], nil
+	})
+	uncached.get("mqtt")
+	uncached.get("mqtt")
+	fmt.Println(loads["mqtt"])
+
+	//Output:
+	//1 <nil> 1
+	//1 <nil> 1
+	//0 true <nil> 1
+	//unavailable 2
+	//1 <nil> 1
+	//2 <nil> 2
+	//0 <nil> 1
+	//4
+}
+
+func Example_endpointPatternCacheExpiry() {
+	loads := 0
+	cache := newEndpointPatternCache(10*time.Millisecond, func(protocolHandler string) ([]model.Endpoint, error) {
+		loads++
+		return []model.Endpoint{}, nil
+	})
+	cache.get("mqtt")
+	cache.get("mqtt")
+	time.Sleep(20 * time.Millisecond)
+	cache.get("mqtt")
+	fmt.Println(loads)
+
+	//Output:
+	//2
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+
+	"github.com/cbroglie/mustache"
+)
+
+//endpoint formats are mustache templates
+//variables are device_uri, service_uri and the config fields of the device type
+//variables may be piped through helper functions: {{device_uri | lowercase | substring 0 8}}
+type endpointFormatFunction struct {
+	minArgs int
+	maxArgs int
+	apply   func(value string, args []int) string
+}
+
+var endpointFormatFunctions = map[string]endpointFormatFunction{
+	"lowercase": {apply: func(value string, args []int) string {
+		return strings.ToLower(value)
+	}},
+	"urlescape": {apply: func(value string, args []int) string {
+		return url.PathEscape(value)
+	}},
+	"hash": {apply: func(value string, args []int) string {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}},
+	//substring start [end]; rune indices, limited to the length of the value
+	"substring": {minArgs: 1, maxArgs: 2, apply: func(value string, args []int) string {
+		runes := []rune(value)
+		start := args[0]
+		end := len(runes)
+		if len(args) > 1 && args[1] < end {
+			end = args[1]
+		}
+		if start >= end {
+			return ""
+		}
+		return string(runes[start:end])
+	}},
+}
+
+type endpointFormatCall struct {
+	function endpointFormatFunction
+	args     []int
+}
+
+type endpointFormatExpression struct {
+	variable string
+	calls    []endpointFormatCall
+}
+
+func parseEndpointFormatExpression(tag string) (result endpointFormatExpression, err error) {
+	parts := strings.Split(tag, "|")
+	result.variable = strings.TrimSpace(parts[0])
+	if result.variable == "" || strings.ContainsAny(result.variable, " \t.") {
+		return result, errors.New("invalid variable '" + result.variable + "'")
+	}
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			return result, errors.New("missing function name in '" + tag + "'")
+		}
+		function, ok := endpointFormatFunctions[fields[0]]
+		if !ok {
+			return result, errors.New("unknown function '" + fields[0] + "'")
+		}
+		if len(fields)-1 < function.minArgs || len(fields)-1 > function.maxArgs {
+			return result, errors.New("wrong number of arguments for function '" + fields[0] + "'")
+		}
+		call := endpointFormatCall{function: function}
+		for _, field := range fields[1:] {
+			arg, err := strconv.Atoi(field)
+			if err != nil || arg < 0 {
+				return result, errors.New("invalid argument '" + field + "' for function '" + fields[0] + "'")
+			}
+			call.args = append(call.args, arg)
+		}
+		result.calls = append(result.calls, call)
+	}
+	return result, nil
+}
+
+func (this endpointFormatExpression) evaluate(variables map[string]string) (result string, err error) {
+	result, ok := variables[this.variable]
+	if !ok {
+		return result, errors.New("missing value for '" + this.variable + "'")
+	}
+	for _, call := range this.calls {
+		result = call.function.apply(result, call.args)
+	}
+	return result, nil
+}
+
+func parseEndpointFormat(format string) (template *mustache.Template, expressions map[string]endpointFormatExpression, err error) {
+	template, err = mustache.ParseString(format)
+	if err != nil {
+		return
+	}
+	expressions = map[string]endpointFormatExpression{}
+	err = parseEndpointFormatTags(template.Tags(), expressions)
+	return
+}
+
+func parseEndpointFormatTags(tags []mustache.Tag, expressions map[string]endpointFormatExpression) (err error) {
+	for _, tag := range tags {
+		if tag.Type() == mustache.Partial {
+			return errors.New("partials are not supported")
+		}
+		expressions[tag.Name()], err = parseEndpointFormatExpression(tag.Name())
+		if err != nil {
+			return err
+		}
+		if tag.Type() == mustache.Section || tag.Type() == mustache.InvertedSection {
+			err = parseEndpointFormatTags(tag.Tags(), expressions)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//checks syntax, functions and that only known variables are used
+func validateEndpointFormat(format string, config []model.ConfigFieldType) (err error) {
+	_, expressions, err := parseEndpointFormat(format)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{"device_uri": true, "service_uri": true}
+	for _, field := range config {
+		known[field.Name] = true
+	}
+	for _, expression := range expressions {
+		if !known[expression.variable] {
+			return errors.New("unknown variable '" + expression.variable + "'")
+		}
+	}
+	return nil
+}
+
+func createEndpointString(format string, device string, service string, config []model.ConfigField) (result string, err error) {
+	if format == "" {
+		return "", nil
+	}
+	variables := map[string]string{"device_uri": device, "service_uri": service}
+	for _, field := range config {
+		variables[field.Name] = field.Value
+	}
+	template, expressions, err := parseEndpointFormat(format)
+	if err != nil {
+		return result, err
+	}
+	//mustache looks up each tag by its complete text
+	context := map[string]string{}
+	for tag, expression := range expressions {
+		context[tag], err = expression.evaluate(variables)
+		if err != nil {
+			return result, err
+		}
+	}
+	result, err = template.Render(context)
+	if err != nil {
+		return result, err
+	}
+	if result == "" {
+		return result, errors.New("endpoint format renders to empty endpoint")
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func Example_endpointFormat() {
+	config := []model.ConfigField{{Name: "room", Value: "Living Room"}}
+	printEndpoint(createEndpointString("sensors/{{device_uri | lowercase}}/{{room | urlescape}}/{{service_uri}}/+", "Dev1", "state", config))
+	printEndpoint(createEndpointString("{{device_uri | hash | substring 0 8}}/{{device_uri | substring 1}}", "dev1", "state", config))
+	printEndpoint(createEndpointString("sensors/{{device_uri}}/{{floor}}", "dev1", "state", config))
+	printEndpoint(createEndpointString("{{room | substring 20}}", "dev1", "state", config))
+	printEndpoint(createEndpointString("", "dev1", "state", config))
+
+	types := []model.ConfigFieldType{{Name: "room"}}
+	fmt.Println(validateEndpointFormat("sensors/{{device_uri}}/{{#room}}{{room | lowercase}}{{/room}}", types))
+	fmt.Println(validateEndpointFormat("sensors/{{device_uri}}/{{floor}}", types))
+	fmt.Println(validateEndpointFormat("sensors/{{device_uri | uppercase}}", types))
+	fmt.Println(validateEndpointFormat("sensors/{{device_uri | substring}}", types))
+	fmt.Println(validateEndpointFormat("sensors/{{device_uri | substring a}}", types))
+	fmt.Println("syntax:", validateEndpointFormat("sensors/{{device_uri", types))
+
+	//Output:
+	//"sensors/dev1/Living%20Room/state/+" <nil>
+	//"cf4b9c1f/ev1" <nil>
+	//"" missing value for 'floor'
+	//"" endpoint format renders to empty endpoint
+	//"" <nil>
+	//<nil>
+	//unknown variable 'floor'
+	//unknown function 'uppercase'
+	//wrong number of arguments for function 'substring'
+	//invalid argument 'a' for function 'substring'
+	//syntax: line 1: unmatched open tag
+}
+
+func printEndpoint(endpoint string, err error) {
+	fmt.Printf("%q %v\n", endpoint, err)
+}
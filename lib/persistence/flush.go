@@ -17,107 +17,322 @@
 package persistence
 
 import (
+	"errors"
 	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 )
 
-func (this *Persistence) FlushDevicetypes() {
-	deviceTypes := []model.DeviceType{}
-	err := this.ordf.SearchAll(&deviceTypes, model.DeviceType{})
-	if err != nil {
-		log.Println("ERROR:", err)
-		return
-	}
-	for _, dt := range deviceTypes {
-		log.Println("flush devicetype", dt.Id)
-		deepDt, err := this.GetDeepDeviceTypeById(dt.Id)
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+//ids of all entities of the kind
+func (this *Persistence) GetFlushIds(kind string) (ids []string, err error) {
+	ids = []string{}
+	switch kind {
+	case model.KindDeviceType:
+		deviceTypes := []model.DeviceType{}
+		err = this.ordf.SearchAll(&deviceTypes, model.DeviceType{})
+		for _, dt := range deviceTypes {
+			ids = append(ids, dt.Id)
 		}
-		err = eventsourcing.PublishDeviceType(deepDt, "")
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+	case model.KindDeviceInstance:
+		instances := []model.DeviceInstance{}
+		err = this.ordf.SearchAll(&instances, model.DeviceInstance{})
+		for _, instance := range instances {
+			ids = append(ids, instance.Id)
 		}
-	}
-}
-
-func (this *Persistence) FlushGateways() {
-	gateways := []model.GatewayRef{}
-	err := this.ordf.SearchAll(&gateways, model.GatewayRef{})
-	if err != nil {
-		log.Println("ERROR:", err)
-		return
-	}
-	for _, gw := range gateways {
-		log.Println("flush gateway", gw.Id)
-		gateway, err := this.GetGateway(gw.Id)
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+	case model.KindGateway:
+		gateways := []model.GatewayRef{}
+		err = this.ordf.SearchAll(&gateways, model.GatewayRef{})
+		for _, gw := range gateways {
+			ids = append(ids, gw.Id)
 		}
-		err = eventsourcing.PublishGateway(gateway, "")
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+	case model.KindValueType:
+		valuetypes := []model.ValueType{}
+		err = this.ordf.SearchAll(&valuetypes, model.ValueType{})
+		for _, vt := range valuetypes {
+			ids = append(ids, vt.Id)
 		}
+	default:
+		err = errors.New("unknown kind: " + kind)
 	}
+	return
 }
 
-func (this *Persistence) FlushDevices() {
-	log.Println("flush deviceinstances")
-	instances := []model.DeviceInstance{}
-	err := this.ordf.SearchAll(&instances, model.DeviceInstance{})
-	if err != nil {
-		log.Println("ERROR: in FlushDevices", err)
-		return
-	}
-	for _, instance := range instances {
-		log.Println("fulsh device-instance", instance.Id)
-		di, err := this.GetDeviceInstanceById(instance.Id)
+//publishes the current state of one entity
+func (this *Persistence) Flush(kind string, id string) (err error) {
+	switch kind {
+	case model.KindDeviceType:
+		deepDt, err := this.GetDeepDeviceTypeById(id)
 		if err != nil {
-			log.Println("ERROR:", err)
-			return
+			return err
+		}
+		_, err = eventsourcing.PublishDeviceType(deepDt, "")
+		return err
+	case model.KindDeviceInstance:
+		di, err := this.GetDeviceInstanceById(id)
+		if err != nil {
+			return err
 		}
 		if di.ImgUrl == "" {
 			dt, err := this.GetDeviceTypeById(di.DeviceType, 1)
 			if err != nil {
-				log.Println("ERROR:", err)
-				return
+				return err
 			}
 			di.ImgUrl = dt.ImgUrl
 		}
-		err = eventsourcing.PublishDeviceInstance(di, "")
+		_, err = eventsourcing.PublishDeviceInstance(di, "")
+		return err
+	case model.KindGateway:
+		gateway, err := this.GetGateway(id)
 		if err != nil {
-			log.Println("ERROR while deleting userless instance", err)
+			return err
+		}
+		_, err = eventsourcing.PublishGateway(gateway, "")
+		return err
+	case model.KindValueType:
+		valuetype, err := this.getValueTypeDeclaration(id)
+		if err != nil {
+			return err
+		}
+		if valuetype.Id == "" || valuetype.Name == "" {
+			return errors.New("unable to load valuetype " + id)
+		}
+		_, err = eventsourcing.PublishValueType(valuetype, "")
+		return err
+	default:
+		return errors.New("unknown kind: " + kind)
+	}
+}
+
+//finished jobs exceeding this count are removed, oldest first
+const maxFinishedFlushJobs = 20
+
+type flushJobEntry struct {
+	job    model.FlushJob
+	cancel chan bool
+}
+
+//in memory registry of background flush jobs
+type flushJobs struct {
+	mux      sync.Mutex
+	sequence int64
+	entries  map[string]*flushJobEntry
+	ids      func(kind string) ([]string, error)
+	flush    func(kind string, id string) error
+}
+
+func newFlushJobs(ids func(kind string) ([]string, error), flush func(kind string, id string) error) *flushJobs {
+	return &flushJobs{entries: map[string]*flushJobEntry{}, ids: ids, flush: flush}
+}
+
+//starts a background job publishing the current state of the requested entities
+//failing entities are reported in the job and do not stop the job
+func (this *Persistence) StartFlush(request model.FlushRequest) (job model.FlushJob, err error) {
+	return this.flushJobs.start(request)
+}
+
+func (this *Persistence) GetFlushJob(id string) (job model.FlushJob, err error) {
+	return this.flushJobs.get(id)
+}
+
+//sorted by start
+func (this *Persistence) ListFlushJobs() (result []model.FlushJob) {
+	return this.flushJobs.list()
+}
+
+//stops a running job after the currently flushed entity
+func (this *Persistence) CancelFlushJob(id string) (err error) {
+	return this.flushJobs.cancel(id)
+}
+
+//the kinds of one job are flushed sequentially in the requested order
+func flushRequestKinds(request model.FlushRequest) (kinds []string, err error) {
+	if request.Kind != "" && len(request.Kinds) > 0 {
+		return kinds, errors.New("expect either kind or kinds")
+	}
+	kinds = request.Kinds
+	if request.Kind != "" {
+		kinds = []string{request.Kind}
+	}
+	if len(kinds) == 0 {
+		return kinds, errors.New("missing kind")
+	}
+	if len(request.Ids) > 0 && len(kinds) > 1 {
+		return kinds, errors.New("ids are only allowed for a single kind")
+	}
+	for _, kind := range kinds {
+		switch kind {
+		case model.KindDeviceType, model.KindDeviceInstance, model.KindGateway, model.KindValueType:
+		default:
+			return kinds, errors.New("unknown kind: " + kind)
 		}
 	}
+	return kinds, nil
 }
 
-func (this *Persistence) FlushValueTypes() {
-	valuetypes := []model.ValueType{}
-	err := this.ordf.SearchAll(&valuetypes, model.ValueType{})
+func (this *flushJobs) start(request model.FlushRequest) (job model.FlushJob, err error) {
+	kinds, err := flushRequestKinds(request)
 	if err != nil {
-		log.Println("ERROR:", err)
-		return
+		return job, err
 	}
-	for _, vt := range valuetypes {
-		log.Println("flush valuetype", vt.Id)
-		valuetype, err := this.GetValueTypeById(vt.Id)
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+	if request.RateLimit < 0 {
+		return job, errors.New("negative rate_limit")
+	}
+	this.mux.Lock()
+	this.sequence++
+	entry := &flushJobEntry{
+		job: model.FlushJob{
+			Id:      strconv.FormatInt(this.sequence, 10),
+			Request: request,
+			State:   model.FlushRunning,
+			Errors:  []model.FlushError{},
+			Started: time.Now(),
+		},
+		cancel: make(chan bool),
+	}
+	this.entries[entry.job.Id] = entry
+	job = copyFlushJob(entry.job)
+	this.mux.Unlock()
+	go this.run(entry, kinds)
+	return job, nil
+}
+
+func (this *flushJobs) run(entry *flushJobEntry, kinds []string) {
+	request := entry.job.Request
+	log.Println("flush", kinds, "job", entry.job.Id)
+	idsByKind := map[string][]string{}
+	total := 0
+	for _, kind := range kinds {
+		ids := request.Ids
+		if len(ids) == 0 {
+			var err error
+			ids, err = this.ids(kind)
+			if err != nil {
+				log.Println("ERROR: unable to load flush ids", kind, err)
+				this.finish(entry, model.FlushFailed, err.Error())
+				return
+			}
 		}
-		if valuetype.Id == "" || valuetype.Name == "" {
-			log.Println("ERROR: unable to load valuetype ", vt)
+		idsByKind[kind] = ids
+		total = total + len(ids)
+	}
+	this.mux.Lock()
+	entry.job.Total = total
+	this.mux.Unlock()
+
+	interval := time.Duration(0)
+	if request.RateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / request.RateLimit)
+	}
+	next := time.Now()
+	for _, kind := range kinds {
+		for _, id := range idsByKind[kind] {
+			if wait := next.Sub(time.Now()); wait > 0 {
+				select {
+				case <-entry.cancel:
+					this.finish(entry, model.FlushCanceled, "")
+					return
+				case <-time.After(wait):
+				}
+			}
+			select {
+			case <-entry.cancel:
+				this.finish(entry, model.FlushCanceled, "")
+				return
+			default:
+			}
+			next = time.Now().Add(interval)
+			err := this.flush(kind, id)
+			this.mux.Lock()
+			entry.job.Done++
+			if err != nil {
+				log.Println("ERROR: unable to flush", kind, id, err)
+				entry.job.Failed++
+				entry.job.Errors = append(entry.job.Errors, model.FlushError{Kind: kind, Id: id, Error: err.Error()})
+			}
+			this.mux.Unlock()
 		}
-		err = eventsourcing.PublishValueType(valuetype, "")
-		if err != nil {
-			log.Println("ERROR:", err)
-			return
+	}
+	this.finish(entry, model.FlushFinished, "")
+}
+
+func (this *flushJobs) finish(entry *flushJobEntry, state string, jobErr string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	now := time.Now()
+	entry.job.State = state
+	entry.job.Error = jobErr
+	entry.job.Finished = &now
+	log.Println("flush job", entry.job.Id, state, entry.job.Done, "/", entry.job.Total, "failed:", entry.job.Failed)
+	this.evict()
+}
+
+//removes the oldest finished jobs exceeding maxFinishedFlushJobs; has to be called with locked mux
+func (this *flushJobs) evict() {
+	finished := []*flushJobEntry{}
+	for _, entry := range this.entries {
+		if entry.job.State != model.FlushRunning {
+			finished = append(finished, entry)
 		}
 	}
+	if len(finished) <= maxFinishedFlushJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].job.Finished.Before(*finished[j].job.Finished)
+	})
+	for _, entry := range finished[:len(finished)-maxFinishedFlushJobs] {
+		delete(this.entries, entry.job.Id)
+	}
+}
+
+func (this *flushJobs) get(id string) (job model.FlushJob, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.entries[id]
+	if !ok {
+		return job, errors.New("unknown flush job: " + id)
+	}
+	return copyFlushJob(entry.job), nil
+}
+
+func (this *flushJobs) list() (result []model.FlushJob) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []model.FlushJob{}
+	for _, entry := range this.entries {
+		result = append(result, copyFlushJob(entry.job))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return
+}
+
+func (this *flushJobs) cancel(id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.entries[id]
+	if !ok {
+		return errors.New("unknown flush job: " + id)
+	}
+	if entry.job.State != model.FlushRunning {
+		return errors.New("flush job is not running: " + id)
+	}
+	select {
+	case <-entry.cancel:
+	default:
+		close(entry.cancel)
+	}
+	return nil
+}
+
+//the errors of running jobs are appended concurrently
+func copyFlushJob(job model.FlushJob) model.FlushJob {
+	job.Errors = append([]model.FlushError{}, job.Errors...)
+	return job
 }
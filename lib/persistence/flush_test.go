@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+//waits until the job is no longer running
+func waitForFlushJob(jobs *flushJobs, id string) model.FlushJob {
+	for i := 0; i < 100; i++ {
+		job, err := jobs.get(id)
+		if err != nil || job.State != model.FlushRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := jobs.get(id)
+	return job
+}
+
+func Example_flushJobs() {
+	mux := sync.Mutex{}
+	flushed := []string{}
+	ids := map[string][]string{
+		model.KindValueType:      {"vt1", "vt2"},
+		model.KindDeviceType:     {"dt1"},
+		model.KindDeviceInstance: {"d1", "d2"},
+		model.KindGateway:        {},
+	}
+	jobs := newFlushJobs(func(kind string) ([]string, error) {
+		if kind == model.KindGateway && len(ids[kind]) > 0 {
+			return nil, errors.New("unable to load gateway ids")
+		}
+		return ids[kind], nil
+	}, func(kind string, id string) error {
+		mux.Lock()
+		defer mux.Unlock()
+		flushed = append(flushed, id)
+		if id == "d1" {
+			return errors.New("unable to load " + id)
+		}
+		return nil
+	})
+
+	//kinds of one job are flushed sequentially in the requested order
+	job, err := jobs.start(model.FlushRequest{Kinds: []string{model.KindValueType, model.KindDeviceType, model.KindDeviceInstance, model.KindGateway}})
+	fmt.Println(job.State, err)
+	job = waitForFlushJob(jobs, job.Id)
+	fmt.Println(job.State, job.Total, job.Done, job.Failed, job.Errors)
+	mux.Lock()
+	fmt.Println(flushed)
+	mux.Unlock()
+
+	_, err = jobs.start(model.FlushRequest{Kind: model.KindDeviceType, Kinds: []string{model.KindGateway}})
+	fmt.Println(err)
+	_, err = jobs.start(model.FlushRequest{Kinds: []string{model.KindDeviceType, model.KindGateway}, Ids: []string{"dt1"}})
+	fmt.Println(err)
+	_, err = jobs.start(model.FlushRequest{Kinds: []string{"unknown"}})
+	fmt.Println(err)
+
+	//canceled jobs stop before the next entity
+	job, _ = jobs.start(model.FlushRequest{Kind: model.KindValueType, RateLimit: 1})
+	fmt.Println(jobs.cancel(job.Id))
+	job = waitForFlushJob(jobs, job.Id)
+	fmt.Println(job.State, job.Done < job.Total, jobs.cancel(job.Id))
+
+	//jobs fail if the ids of a kind can not be loaded
+	ids[model.KindGateway] = []string{"gw1"}
+	job, _ = jobs.start(model.FlushRequest{Kinds: []string{model.KindDeviceType, model.KindGateway}})
+	job = waitForFlushJob(jobs, job.Id)
+	fmt.Println(job.State, job.Error, job.Done)
+	ids[model.KindGateway] = []string{}
+
+	//finished jobs are evicted, oldest first
+	last := ""
+	for i := 0; i < maxFinishedFlushJobs+5; i++ {
+		job, _ = jobs.start(model.FlushRequest{Kind: model.KindGateway})
+		waitForFlushJob(jobs, job.Id)
+		last = job.Id
+	}
+	list := jobs.list()
+	_, err = jobs.get("1")
+	fmt.Println(len(list), list[len(list)-1].Id == last, err)
+
+	//Output:
+	//running <nil>
+	//finished 5 5 1 [{deviceInstance d1 unable to load d1}]
+	//[vt1 vt2 dt1 d1 d2]
+	//expect either kind or kinds
+	//ids are only allowed for a single kind
+	//unknown kind: unknown
+	//<nil>
+	//canceled true flush job is not running: 2
+	//failed unable to load gateway ids 0
+	//20 true unknown flush job: 1
+}
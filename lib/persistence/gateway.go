@@ -22,6 +22,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 )
 
@@ -48,8 +49,72 @@ func (this *Persistence) DeleteGateway(id string) error {
 	if gateway.Name == "" {
 		return nil
 	}
+	err = this.deleteGatewayState(id)
+	if err != nil {
+		return err
+	}
 	_, err = this.ordf.Delete(gateway)
-	return err
+	if err != nil {
+		return err
+	}
+	err = this.DeleteGatewayCredentials(id)
+	if err != nil {
+		return err
+	}
+	err = this.DeleteGatewayClaimTokens(id)
+	if err != nil {
+		return err
+	}
+	err = this.changeGatewayListParent(gateway.Gateways, GATEWAY_NONE)
+	if err != nil {
+		return err
+	}
+	if gateway.Parent != GATEWAY_NONE {
+		parent, err := this.getGatewayFlat(gateway.Parent)
+		if err != nil {
+			return err
+		}
+		if parent.Name != "" {
+			remaining := []string{}
+			for _, child := range parent.Gateways {
+				if child != id {
+					remaining = append(remaining, child)
+				}
+			}
+			return this.SetGatway(parent.Id, parent.Name, "", parent.Devices, remaining)
+		}
+	}
+	return nil
+}
+
+//returns the gateway with its connected devices and recursively its connected gateways
+func (this *Persistence) GetGatewayTree(id string) (tree model.GatewayTree, err error) {
+	return this.getGatewayTree(id, map[string]bool{})
+}
+
+func (this *Persistence) getGatewayTree(id string, visited map[string]bool) (tree model.GatewayTree, err error) {
+	visited[id] = true
+	gateway, err := this.GetGateway(id)
+	if err != nil {
+		return tree, err
+	}
+	tree = model.GatewayTree{Id: gateway.Id, Name: gateway.Name, Hash: gateway.Hash, Parent: gateway.Parent, Online: gateway.Online, LastSeen: gateway.LastSeen, Devices: gateway.Devices, Gateways: []model.GatewayTree{}}
+	if tree.Devices == nil {
+		tree.Devices = []model.DeviceInstance{}
+	}
+	for _, childId := range gateway.Gateways {
+		if visited[childId] {
+			continue
+		}
+		child, err := this.getGatewayTree(childId, visited)
+		if err != nil {
+			return tree, err
+		}
+		if child.Name != "" {
+			tree.Gateways = append(tree.Gateways, child)
+		}
+	}
+	return tree, nil
 }
 
 func (this *Persistence) getGatewayFlat(id string) (gateway model.GatewayFlat, err error) {
@@ -87,6 +152,46 @@ func (this *Persistence) changeDeviceListGateway(deviceIds []string, gatewayId s
 	return err
 }
 
+func (this *Persistence) changeGatewayParent(gatewayId string, parentId string) (err error) {
+	current := model.GatewayParentRelation{Id: gatewayId}
+	err = this.ordf.Select(&current)
+	if err != nil {
+		return err
+	}
+	name, err := this.GetGatewayName(gatewayId)
+	if err != nil || name == "" {
+		return err
+	}
+	new := model.GatewayParentRelation{Id: gatewayId, Parent: parentId}
+	_, err = this.ordf.Update(current, new)
+	return err
+}
+
+func (this *Persistence) changeGatewayListParent(gatewayIds []string, parentId string) (err error) {
+	for _, id := range gatewayIds {
+		tempErr := this.changeGatewayParent(id, parentId)
+		if tempErr != nil {
+			log.Println("ERROR: changeGatewayListParent(): ", tempErr)
+			err = tempErr
+		}
+	}
+	return err
+}
+
+//resets the hash of the gateway; SetGatway propagates the change to the parent gateways
+func (this *Persistence) resetGatewayHash(id string) (err error) {
+	gateway, err := this.GetGateway(id)
+	if err != nil {
+		return err
+	}
+	if gateway.Name == "" || gateway.Hash == "" {
+		return nil
+	}
+	gateway.Hash = ""
+	_, err = eventsourcing.PublishGateway(gateway, "")
+	return err
+}
+
 func (this *Persistence) CheckClearGateway(id string) error {
 	gw, err := this.GetGateway(id)
 	if err != nil {
@@ -104,6 +209,26 @@ func (this *Persistence) GetGatewayName(id string) (name string, err error) {
 	return current.Name, err
 }
 
+//removes the sync state and the connection which are stored with the gateway
+func (this *Persistence) deleteGatewayState(id string) (err error) {
+	syncState, err := this.GetGatewaySyncState(id)
+	if err != nil {
+		return err
+	}
+	if syncState.Hash != "" || len(syncState.Devices) > 0 {
+		_, err = this.ordf.Delete(syncState)
+		if err != nil {
+			return err
+		}
+	}
+	connection, err := this.GetGatewayConnection(id)
+	if err != nil {
+		return err
+	}
+	_, err = this.ordf.Delete(connection)
+	return err
+}
+
 func gatewayDeviceDiff(old []string, new []string) (add []string, remove []string) {
 	compare := func(X, Y []string) []string {
 		m := make(map[string]int)
@@ -139,7 +264,62 @@ func (this *Persistence) GatewayCheckCommit(id string, ref model.GatewayRef) (er
 		log.Println("ERROR: gateway ("+id+") may not own devices: ", ref.Devices)
 		return errors.New("gateway (" + id + ") may not own devices")
 	}
-	return
+	return this.gatewayMayOwnGateways(id, ref.Gateways)
+}
+
+//the connected gateways would violate the tree
+type gatewayTreeError string
+
+func (this gatewayTreeError) Error() string {
+	return string(this)
+}
+
+//connected gateways have to exist and may not be connected by a other gateway or be a ancestor of the gateway
+func (this *Persistence) gatewayMayOwnGateways(gatewayId string, gateways []string) (err error) {
+	if len(gateways) == 0 {
+		return nil
+	}
+	ancestors, err := this.getGatewayAncestors(gatewayId)
+	if err != nil {
+		return err
+	}
+	for _, child := range gateways {
+		if child == gatewayId || ancestors[child] {
+			return gatewayTreeError("gateway (" + gatewayId + ") may not connect gateway (" + child + "): cycle")
+		}
+		rel := model.GatewayParentRelation{Id: child}
+		err = this.ordf.Select(&rel)
+		if err != nil {
+			return err
+		}
+		name, err := this.GetGatewayName(child)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return gatewayTreeError("gateway (" + child + ") does not exist")
+		}
+		if rel.Parent != gatewayId && rel.Parent != GATEWAY_NONE {
+			return gatewayTreeError("gateway (" + child + ") is connected by a other gateway")
+		}
+	}
+	return nil
+}
+
+func (this *Persistence) getGatewayAncestors(id string) (ancestors map[string]bool, err error) {
+	ancestors = map[string]bool{}
+	for id != GATEWAY_NONE && !ancestors[id] {
+		rel := model.GatewayParentRelation{Id: id}
+		err = this.ordf.Select(&rel)
+		if err != nil {
+			return ancestors, err
+		}
+		if rel.Parent != GATEWAY_NONE {
+			ancestors[rel.Parent] = true
+		}
+		id = rel.Parent
+	}
+	return ancestors, nil
 }
 
 func (this *Persistence) gatewayMayOwnDevices(gatewayId string, devices []string) (result bool, err error) {
@@ -171,32 +351,95 @@ func (this *Persistence) ProvideGateway(id string, owner string) (gateway model.
 	return gateway, true, err
 }
 
-func (this *Persistence) SetGatway(id string, name string, hash string, devices []string) (err error) {
-	newGw := model.GatewayFlat{Id: id, Name: name, Hash: hash, Devices: devices}
-	log.Println("DEBUG: set gateway: ", newGw)
+//gateways == nil keeps the connected gateways
+//changes of a gateway connected by a other gateway reset the hash of the parent gateway
+func (this *Persistence) SetGatway(id string, name string, hash string, devices []string, gateways []string) (err error) {
 	current := model.GatewayFlat{Id: id}
 	err = this.ordf.Select(&current)
 	if err != nil {
 		return err
 	}
+	if gateways == nil {
+		gateways = current.Gateways
+	}
+	//the tree may have changed since the api checked the commit
+	addGateways, removeGateways := gatewayDeviceDiff(current.Gateways, gateways)
+	err = this.gatewayMayOwnGateways(id, addGateways)
+	if _, invalid := err.(gatewayTreeError); invalid {
+		return eventsourcing.InvalidCommandError{Reason: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	newGw := model.GatewayFlat{Id: id, Name: name, Hash: hash, Devices: devices, Gateways: gateways}
+	log.Println("DEBUG: set gateway: ", newGw)
 	if current.Name == "" {
 		_, err = this.ordf.Insert(newGw)
 		if err != nil {
 			return err
 		}
 		err = this.changeDeviceListGateway(devices, id)
-	} else {
-		_, err = this.ordf.Update(current, newGw)
 		if err != nil {
 			return err
 		}
-		add, remove := gatewayDeviceDiff(current.Devices, devices)
-		err = this.changeDeviceListGateway(remove, GATEWAY_NONE)
+		err = this.changeGatewayListParent(gateways, id)
+		return
+	}
+	_, err = this.ordf.Update(current, newGw)
+	if err != nil {
+		return err
+	}
+	add, remove := gatewayDeviceDiff(current.Devices, devices)
+	err = this.changeDeviceListGateway(remove, GATEWAY_NONE)
+	if err != nil {
+		return err
+	}
+	err = this.changeDeviceListGateway(add, id)
+	if err != nil {
+		return err
+	}
+	err = this.changeGatewayListParent(removeGateways, GATEWAY_NONE)
+	if err != nil {
+		return err
+	}
+	err = this.changeGatewayListParent(addGateways, id)
+	if err != nil {
+		return err
+	}
+	if current.Hash != hash || len(add) > 0 || len(remove) > 0 || len(addGateways) > 0 || len(removeGateways) > 0 {
+		parent := model.GatewayParentRelation{Id: id}
+		err = this.ordf.Select(&parent)
 		if err != nil {
 			return err
 		}
-		err = this.changeDeviceListGateway(add, id)
+		if parent.Parent != GATEWAY_NONE {
+			err = this.resetGatewayHash(parent.Parent)
+		}
 	}
+	return
+}
+
+func (this *Persistence) GetGatewayConnection(id string) (connection model.GatewayConnection, err error) {
+	connection.Id = id
+	err = this.ordf.Select(&connection)
+	return
+}
+
+//ignored if the gateway does not exist
+func (this *Persistence) SetGatewayConnection(connection model.GatewayConnection) (err error) {
+	name, err := this.GetGatewayName(connection.Id)
+	if err != nil || name == "" {
+		return err
+	}
+	current, err := this.GetGatewayConnection(connection.Id)
+	if err != nil {
+		return err
+	}
+	_, err = this.ordf.Update(current, connection)
+	return err
+}
 
+func (this *Persistence) GetOnlineGatewayConnections() (connections []model.GatewayConnection, err error) {
+	err = this.ordf.SearchAll(&connections, model.GatewayConnection{Online: true})
 	return
 }
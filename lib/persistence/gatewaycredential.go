@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+//returns the claim token with a new id; the token is stored by a CLAIM command
+func (this *Persistence) ProvideGatewayClaimToken(token model.GatewayClaimToken) (result model.GatewayClaimToken, err error) {
+	token.Id = ""
+	err = this.ordf.SetIdDeep(&token)
+	return token, err
+}
+
+//token.Id is empty if no token with the hash exists
+func (this *Persistence) GetGatewayClaimTokenByHash(hash string) (token model.GatewayClaimToken, err error) {
+	tokens := []model.GatewayClaimToken{}
+	err = this.ordf.SearchAll(&tokens, model.GatewayClaimToken{Hash: hash})
+	if err != nil || len(tokens) == 0 {
+		return token, err
+	}
+	return tokens[0], nil
+}
+
+func (this *Persistence) GetGatewayClaimTokens(gatewayId string) (tokens []model.GatewayClaimToken, err error) {
+	tokens = []model.GatewayClaimToken{}
+	err = this.ordf.SearchAll(&tokens, model.GatewayClaimToken{Gateway: gatewayId})
+	return
+}
+
+//a gateway has at most one claim token; older tokens of the gateway are removed
+func (this *Persistence) SetGatewayClaimToken(token model.GatewayClaimToken) (err error) {
+	if token.Id == "" || token.Gateway == "" {
+		return errors.New("claim token needs id and gateway")
+	}
+	err = this.DeleteGatewayClaimTokens(token.Gateway)
+	if err != nil {
+		return err
+	}
+	_, err = this.ordf.Insert(token)
+	return err
+}
+
+func (this *Persistence) DeleteGatewayClaimTokens(gatewayId string) (err error) {
+	tokens, err := this.GetGatewayClaimTokens(gatewayId)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		_, err = this.ordf.Delete(token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//returns the credential with a new id; the credential is stored by a CREDENTIAL command
+func (this *Persistence) ProvideGatewayCredential(credential model.GatewayCredential) (result model.GatewayCredential, err error) {
+	credential.Id = ""
+	err = this.ordf.SetIdDeep(&credential)
+	return credential, err
+}
+
+func (this *Persistence) GetGatewayCredential(id string) (credential model.GatewayCredential, err error) {
+	credential.Id = id
+	err = this.ordf.Select(&credential)
+	return
+}
+
+func (this *Persistence) GetGatewayCredentials(gatewayId string) (credentials []model.GatewayCredential, err error) {
+	credentials = []model.GatewayCredential{}
+	err = this.ordf.SearchAll(&credentials, model.GatewayCredential{Gateway: gatewayId})
+	return
+}
+
+//credential.Id is empty if no key with the hash exists
+func (this *Persistence) GetGatewayCredentialByHash(hash string) (credential model.GatewayCredential, err error) {
+	return this.findGatewayCredential(model.GatewayCredential{Kind: model.GatewayCredentialKey, Hash: hash})
+}
+
+//credential.Id is empty if the subject is not registered for a gateway
+func (this *Persistence) GetGatewayCredentialBySubject(subject string) (credential model.GatewayCredential, err error) {
+	return this.findGatewayCredential(model.GatewayCredential{Kind: model.GatewayCredentialJwt, Subject: subject})
+}
+
+func (this *Persistence) findGatewayCredential(query model.GatewayCredential) (credential model.GatewayCredential, err error) {
+	credentials := []model.GatewayCredential{}
+	err = this.ordf.SearchAll(&credentials, query)
+	if err != nil || len(credentials) == 0 {
+		return credential, err
+	}
+	return credentials[0], nil
+}
+
+func (this *Persistence) SetGatewayCredential(credential model.GatewayCredential) (err error) {
+	if credential.Id == "" || credential.Gateway == "" {
+		return errors.New("credential needs id and gateway")
+	}
+	current, err := this.GetGatewayCredential(credential.Id)
+	if err != nil {
+		return err
+	}
+	if current.Gateway == "" {
+		_, err = this.ordf.Insert(credential)
+		return err
+	}
+	_, err = this.ordf.Update(current, credential)
+	return err
+}
+
+func (this *Persistence) DeleteGatewayCredential(id string) (err error) {
+	credential, err := this.GetGatewayCredential(id)
+	if err != nil {
+		return err
+	}
+	if credential.Gateway == "" {
+		return nil
+	}
+	_, err = this.ordf.Delete(credential)
+	return err
+}
+
+func (this *Persistence) DeleteGatewayCredentials(gatewayId string) (err error) {
+	credentials, err := this.GetGatewayCredentials(gatewayId)
+	if err != nil {
+		return err
+	}
+	for _, credential := range credentials {
+		_, err = this.ordf.Delete(credential)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
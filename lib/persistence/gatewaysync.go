@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/persistence/ordf"
+)
+
+//delta between the state known by the gateway (requestHash) and the current state of its devices
+//current has to be stored (SetGatewaySyncState) if it differs from stored
+func (this *Persistence) GetGatewaySync(id string, requestHash string) (result model.GatewaySyncResponse, current model.GatewaySyncState, stored model.GatewaySyncState, err error) {
+	devices, current, err := this.getGatewaySyncDevices(id)
+	if err != nil {
+		return
+	}
+	stored, err = this.GetGatewaySyncState(id)
+	if err != nil {
+		return
+	}
+	result = gatewaySyncDelta(devices, current, stored, requestHash)
+	return
+}
+
+//devices sorted by id and the sync state with the deterministic hash of the gateway
+func (this *Persistence) getGatewaySyncDevices(id string) (devices []model.GatewaySyncDevice, state model.GatewaySyncState, err error) {
+	gateway, err := this.getGatewayFlat(id)
+	if err != nil {
+		return
+	}
+	ids := append([]string{}, gateway.Devices...)
+	sort.Strings(ids)
+	deviceTypes := map[string]model.DeviceType{}
+	deviceTypeHashes := map[string]string{}
+	devices = []model.GatewaySyncDevice{}
+	state = model.GatewaySyncState{Id: id, Devices: []string{}}
+	for _, deviceId := range ids {
+		device, err := this.GetDeviceInstanceById(deviceId)
+		if err != nil {
+			return devices, state, err
+		}
+		if device.Url == "" {
+			continue
+		}
+		deviceType, ok := deviceTypes[device.DeviceType]
+		if !ok {
+			deviceType, err = this.GetDeepDeviceTypeById(device.DeviceType)
+			if err != nil {
+				return devices, state, err
+			}
+			deviceTypes[device.DeviceType] = deviceType
+			deviceTypeHashes[device.DeviceType], err = ordf.StructHash(deviceType)
+			if err != nil {
+				return devices, state, err
+			}
+		}
+		endpoints, err := this.getEndpointsByDevice(deviceId)
+		if err != nil {
+			return devices, state, err
+		}
+		syncDevice := model.GatewaySyncDevice{Device: device, DeviceType: deviceType, Endpoints: endpoints}
+		syncDevice.Hash, err = gatewaySyncDeviceHash(syncDevice, deviceTypeHashes[device.DeviceType])
+		if err != nil {
+			return devices, state, err
+		}
+		devices = append(devices, syncDevice)
+		state.Devices = append(state.Devices, deviceId+" "+syncDevice.Hash)
+	}
+	state.Hash = hashLines(state.Devices)
+	return devices, state, nil
+}
+
+//endpoint ids change on each update, so only their content is hashed
+func gatewaySyncDeviceHash(device model.GatewaySyncDevice, deviceTypeHash string) (hash string, err error) {
+	deviceHash, err := ordf.StructHash(device.Device)
+	if err != nil {
+		return hash, err
+	}
+	lines := []string{"device " + deviceHash, "device_type " + deviceTypeHash}
+	for _, endpoint := range device.Endpoints {
+		lines = append(lines, "endpoint "+strings.Join([]string{endpoint.ProtocolHandler, endpoint.Role, endpoint.Service, endpoint.Endpoint}, " "))
+	}
+	return hashLines(lines), nil
+}
+
+func hashLines(lines []string) string {
+	sorted := append([]string{}, lines...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+//full sync if the gateway hash is unknown (empty or not the last stored state)
+func gatewaySyncDelta(devices []model.GatewaySyncDevice, current model.GatewaySyncState, stored model.GatewaySyncState, requestHash string) (result model.GatewaySyncResponse) {
+	result = model.GatewaySyncResponse{Hash: current.Hash, Added: []model.GatewaySyncDevice{}, Changed: []model.GatewaySyncDevice{}, Removed: []string{}}
+	if requestHash == current.Hash {
+		return
+	}
+	if requestHash == "" || requestHash != stored.Hash {
+		result.Full = true
+		result.Added = devices
+		return
+	}
+	previous := map[string]string{}
+	for _, entry := range stored.Devices {
+		parts := strings.SplitN(entry, " ", 2)
+		if len(parts) == 2 {
+			previous[parts[0]] = parts[1]
+		}
+	}
+	for _, device := range devices {
+		hash, known := previous[device.Device.Id]
+		if !known {
+			result.Added = append(result.Added, device)
+		} else if hash != device.Hash {
+			result.Changed = append(result.Changed, device)
+		}
+		delete(previous, device.Device.Id)
+	}
+	for id := range previous {
+		result.Removed = append(result.Removed, id)
+	}
+	sort.Strings(result.Removed)
+	return
+}
+
+func (this *Persistence) GetGatewaySyncState(id string) (state model.GatewaySyncState, err error) {
+	state.Id = id
+	err = this.ordf.Select(&state)
+	return
+}
+
+//ignored if the gateway does not exist
+func (this *Persistence) SetGatewaySyncState(state model.GatewaySyncState) (err error) {
+	name, err := this.GetGatewayName(state.Id)
+	if err != nil || name == "" {
+		return err
+	}
+	current, err := this.GetGatewaySyncState(state.Id)
+	if err != nil {
+		return err
+	}
+	_, err = this.ordf.Update(current, state)
+	return err
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/persistence/ordf"
+)
+
+func Example_gatewaySync() {
+	//hashes do not depend on the order of slices
+	a, _ := ordf.StructHash(model.DeviceInstance{Id: "http://device/1", Name: "lamp", Tags: []string{"a:1", "b:2"}})
+	b, _ := ordf.StructHash(model.DeviceInstance{Id: "http://device/1", Name: "lamp", Tags: []string{"b:2", "a:1"}})
+	c, _ := ordf.StructHash(model.DeviceInstance{Id: "http://device/1", Name: "lamp2", Tags: []string{"b:2", "a:1"}})
+	fmt.Println(a == b, a == c)
+
+	devices := []model.GatewaySyncDevice{
+		{Device: model.DeviceInstance{Id: "d1"}, Hash: "h1"},
+		{Device: model.DeviceInstance{Id: "d2"}, Hash: "h2-new"},
+		{Device: model.DeviceInstance{Id: "d4"}, Hash: "h4"},
+	}
+	current := model.GatewaySyncState{Hash: "current", Devices: []string{"d1 h1", "d2 h2-new", "d4 h4"}}
+	stored := model.GatewaySyncState{Hash: "stored", Devices: []string{"d1 h1", "d2 h2", "d3 h3"}}
+
+	printDelta(gatewaySyncDelta(devices, current, stored, "current"))
+	printDelta(gatewaySyncDelta(devices, current, stored, "stored"))
+	printDelta(gatewaySyncDelta(devices, current, stored, "unknown"))
+	printDelta(gatewaySyncDelta(devices, current, stored, ""))
+
+	//Output:
+	//true false
+	//current full=false added=[] changed=[] removed=[]
+	//current full=false added=[d4] changed=[d2] removed=[d3]
+	//current full=true added=[d1 d2 d4] changed=[] removed=[]
+	//current full=true added=[d1 d2 d4] changed=[] removed=[]
+}
+
+func printDelta(delta model.GatewaySyncResponse) {
+	ids := func(devices []model.GatewaySyncDevice) (result []string) {
+		for _, device := range devices {
+			result = append(result, device.Device.Id)
+		}
+		return
+	}
+	fmt.Printf("%s full=%v added=%v changed=%v removed=%v\n", delta.Hash, delta.Full, ids(delta.Added), ids(delta.Changed), delta.Removed)
+}
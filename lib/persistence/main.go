@@ -17,16 +17,20 @@
 package persistence
 
 import (
+	"time"
+
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/persistence/ordf"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
 
 type Persistence struct {
-	ordf ordf.Persistence
+	ordf             ordf.Persistence
+	flushJobs        *flushJobs
+	endpointPatterns *endpointPatternCache
 }
 
 func New() *Persistence {
-	return &Persistence{
+	result := &Persistence{
 		ordf: ordf.Persistence{
 			Endpoint:  util.Config.SparqlEndpoint,
 			Graph:     util.Config.RdfGraph,
@@ -35,6 +39,9 @@ func New() *Persistence {
 			SparqlLog: util.Config.SparqlLog,
 		},
 	}
+	result.flushJobs = newFlushJobs(result.GetFlushIds, result.Flush)
+	result.endpointPatterns = newEndpointPatternCache(time.Duration(util.Config.EndpointPatternCacheTTL)*time.Second, result.loadEndpointPatterns)
+	return result
 }
 
 func (this *Persistence) SetId(element interface{}) error {
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+//structural description of a resolved value type; ignores ids, names and descriptions
+func valueTypeSignature(valueType model.ValueType) string {
+	fields := []string{}
+	for _, field := range valueType.Fields {
+		name := strconv.Quote(field.Name)
+		if field.Optional {
+			name = name + "?"
+		}
+		fields = append(fields, name+":"+valueTypeSignature(field.Type))
+	}
+	sort.Strings(fields)
+	return "(" + valueType.BaseType + " " + strconv.Quote(valueType.Literal) + " {" + strings.Join(fields, ",") + "})"
+}
+
+func valueTypeSignatureHash(valueType model.ValueType) string {
+	hash := sha1.Sum([]byte(valueTypeSignature(valueType)))
+	return hex.EncodeToString(hash[:])
+}
+
+//groups all value types by structure; only groups with more than one value type are returned
+func (this *Persistence) FindEquivalentValueTypes() (clusters []model.ValueTypeCluster, err error) {
+	valueTypes := []model.ValueType{}
+	err = this.ordf.SearchAll(&valueTypes, model.ValueType{})
+	if err != nil {
+		return []model.ValueTypeCluster{}, err
+	}
+	resolved := []model.ValueType{}
+	for _, vt := range valueTypes {
+		valueType, err := this.GetValueTypeById(vt.Id)
+		if err != nil {
+			return []model.ValueTypeCluster{}, err
+		}
+		resolved = append(resolved, valueType)
+	}
+	return clusterValueTypes(resolved), nil
+}
+
+//expects value types with resolved inheritance
+func clusterValueTypes(valueTypes []model.ValueType) (clusters []model.ValueTypeCluster) {
+	clusters = []model.ValueTypeCluster{}
+	index := map[string][]model.ValueType{}
+	for _, valueType := range valueTypes {
+		signature := valueTypeSignatureHash(valueType)
+		index[signature] = append(index[signature], model.ValueType{Id: valueType.Id, Name: valueType.Name, Description: valueType.Description, BaseType: valueType.BaseType, Extends: valueType.Extends})
+	}
+	for signature, members := range index {
+		if len(members) > 1 {
+			model.SortValueTypes(&members)
+			clusters = append(clusters, model.ValueTypeCluster{Signature: signature, ValueTypes: members})
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].ValueTypes) != len(clusters[j].ValueTypes) {
+			return len(clusters[i].ValueTypes) > len(clusters[j].ValueTypes)
+		}
+		return clusters[i].Signature < clusters[j].Signature
+	})
+	return
+}
+
+func (this *Persistence) CheckValueTypeMerge(canonical string, duplicates []string) (err error) {
+	return checkValueTypeMerge(canonical, duplicates, this.GetValueTypeById)
+}
+
+//getValueType returns value types with resolved inheritance and a empty name for unknown ids
+func checkValueTypeMerge(canonical string, duplicates []string, getValueType func(id string) (model.ValueType, error)) (err error) {
+	if len(duplicates) == 0 {
+		return errors.New("missing duplicates")
+	}
+	canonicalType, err := getValueType(canonical)
+	if err != nil {
+		return err
+	}
+	if canonicalType.Name == "" {
+		return errors.New("unknown canonical valuetype id: " + canonical)
+	}
+	//extending a duplicate through any ancestor would let the canonical value type extend itself after the merge
+	ancestors := []string{}
+	for extends := canonicalType.Extends; extends != "" && !contains(ancestors, extends); {
+		ancestors = append(ancestors, extends)
+		parent, err := getValueType(extends)
+		if err != nil {
+			return err
+		}
+		extends = parent.Extends
+	}
+	signature := valueTypeSignature(canonicalType)
+	for _, id := range duplicates {
+		if id == canonical {
+			return errors.New("canonical valuetype is listed as duplicate")
+		}
+		if contains(ancestors, id) {
+			return errors.New("canonical valuetype extends duplicate " + id)
+		}
+		duplicate, err := getValueType(id)
+		if err != nil {
+			return err
+		}
+		if duplicate.Name == "" {
+			return errors.New("unknown duplicate valuetype id: " + id)
+		}
+		if valueTypeSignature(duplicate) != signature {
+			return errors.New("valuetype " + id + " is not structurally equivalent to " + canonical)
+		}
+	}
+	return nil
+}
+
+//replaces all references to the duplicates with references to the canonical value type,
+//republishes affected device types and publishes the deletion of the duplicates once nothing references them
+//each step is repeatable, so a retry completes a partially applied merge
+func (this *Persistence) MergeValueTypes(canonical string, duplicates []string) (err error) {
+	for _, duplicate := range duplicates {
+		err = this.replaceValueTypeReferences(duplicate, canonical)
+		if err != nil {
+			return err
+		}
+	}
+	//device types of the duplicates use the canonical value type now, also if a previous attempt rewrote them
+	usages, err := this.GetValueTypeUsages(canonical)
+	if err != nil {
+		return err
+	}
+	deviceTypes := map[string]bool{}
+	for _, usage := range usages {
+		if usage.Kind != model.UsageDeviceType || deviceTypes[usage.Id] {
+			continue
+		}
+		deviceTypes[usage.Id] = true
+		deviceType, err := this.GetDeepDeviceTypeById(usage.Id)
+		if err != nil {
+			return err
+		}
+		_, err = eventsourcing.PublishDeviceType(deviceType, "")
+		if err != nil {
+			return err
+		}
+	}
+	for _, duplicate := range duplicates {
+		_, err = eventsourcing.PublishValueTypeRemove(duplicate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *Persistence) replaceValueTypeReferences(old string, new string) (err error) {
+	fields := []model.FieldType{}
+	err = this.ordf.SearchAll(&fields, model.FieldType{Type: model.ValueType{Id: old}})
+	if err != nil {
+		return
+	}
+	for _, field := range fields {
+		replacement := field
+		replacement.Type = model.ValueType{Id: new}
+		_, err = this.ordf.Update(field, replacement)
+		if err != nil {
+			return
+		}
+	}
+
+	assignments := []model.TypeAssignment{}
+	err = this.ordf.SearchAll(&assignments, model.TypeAssignment{Type: model.ValueType{Id: old}})
+	if err != nil {
+		return
+	}
+	for _, assignment := range assignments {
+		replacement := assignment
+		replacement.Type = model.ValueType{Id: new}
+		_, err = this.ordf.Update(assignment, replacement)
+		if err != nil {
+			return
+		}
+	}
+
+	extending := []model.ValueType{}
+	err = this.ordf.SearchAll(&extending, model.ValueType{Extends: old})
+	if err != nil {
+		return
+	}
+	for _, valueType := range extending {
+		replacement := valueType
+		replacement.Extends = new
+		_, err = this.ordf.Update(valueType, replacement)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func mergeTestValueType(id string, extends string, fields ...model.FieldType) model.ValueType {
+	return model.ValueType{Id: id, Name: "name " + id, Description: "description " + id, BaseType: model.StructBaseType, Extends: extends, Fields: fields}
+}
+
+func mergeTestField(name string, baseType string) model.FieldType {
+	return model.FieldType{Id: "field " + name, Name: name, Type: model.ValueType{Id: "type " + name, Name: name, BaseType: baseType}}
+}
+
+func Example_valueTypeSignature() {
+	a := mergeTestValueType("a", "", mergeTestField("temp", model.XsdFloat), mergeTestField("unit", model.XsdString))
+	//other ids, names, descriptions and field order
+	b := mergeTestValueType("b", "p", mergeTestField("unit", model.XsdString), mergeTestField("temp", model.XsdFloat))
+	fmt.Println(valueTypeSignature(a))
+	fmt.Println(valueTypeSignature(a) == valueTypeSignature(b), valueTypeSignatureHash(a) == valueTypeSignatureHash(b))
+
+	optional := mergeTestValueType("c", "", mergeTestField("temp", model.XsdFloat), mergeTestField("unit", model.XsdString))
+	optional.Fields[1].Optional = true
+	fmt.Println(valueTypeSignature(a) == valueTypeSignature(optional))
+
+	renamed := mergeTestValueType("d", "", mergeTestField("temperature", model.XsdFloat), mergeTestField("unit", model.XsdString))
+	fmt.Println(valueTypeSignature(a) == valueTypeSignature(renamed))
+
+	literal := model.ValueType{Id: "e", BaseType: model.XsdString, Literal: "on"}
+	fmt.Println(valueTypeSignature(literal), valueTypeSignature(literal) == valueTypeSignature(model.ValueType{Id: "f", BaseType: model.XsdString, Literal: "off"}))
+
+	//Output:
+	//(http://www.sepl.wifa.uni-leipzig.de/ontlogies/device-repo#structure "" {"temp":(http://www.w3.org/2001/XMLSchema#decimal "" {}),"unit":(http://www.w3.org/2001/XMLSchema#string "" {})})
+	//true true
+	//false
+	//false
+	//(http://www.w3.org/2001/XMLSchema#string "on" {}) false
+}
+
+func Example_clusterValueTypes() {
+	clusters := clusterValueTypes([]model.ValueType{
+		mergeTestValueType("b", "", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("x", "", mergeTestField("on", model.XsdBool)),
+		mergeTestValueType("a", "", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("y", "", mergeTestField("on", model.XsdBool)),
+		mergeTestValueType("c", "x", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("single", "", mergeTestField("level", model.XsdInt)),
+	})
+	for _, cluster := range clusters {
+		ids := []string{}
+		for _, valueType := range cluster.ValueTypes {
+			ids = append(ids, valueType.Id+"/"+valueType.Extends+"/"+fmt.Sprint(len(valueType.Fields)))
+		}
+		fmt.Println(len(cluster.Signature), ids)
+	}
+	fmt.Println(len(clusterValueTypes(nil)))
+
+	//Output:
+	//40 [a//0 b//0 c/x/0]
+	//40 [x//0 y//0]
+	//0
+}
+
+func Example_checkValueTypeMerge() {
+	valueTypes := map[string]model.ValueType{}
+	for _, valueType := range []model.ValueType{
+		mergeTestValueType("canonical", "parent", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("parent", "grandparent"),
+		mergeTestValueType("grandparent", ""),
+		mergeTestValueType("duplicate", "", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("other", "", mergeTestField("on", model.XsdBool)),
+		mergeTestValueType("ancestor", "", mergeTestField("temp", model.XsdFloat)),
+		mergeTestValueType("child", "ancestor"),
+		mergeTestValueType("grandchild", "child", mergeTestField("temp", model.XsdFloat)),
+	} {
+		valueTypes[valueType.Id] = valueType
+	}
+	getValueType := func(id string) (model.ValueType, error) {
+		if id == "broken" {
+			return model.ValueType{}, errors.New("unavailable")
+		}
+		return valueTypes[id], nil
+	}
+	//inheritance is resolved by getValueType; the test types declare the resolved fields
+	fmt.Println(checkValueTypeMerge("canonical", []string{"duplicate"}, getValueType))
+	fmt.Println(checkValueTypeMerge("canonical", []string{}, getValueType))
+	fmt.Println(checkValueTypeMerge("unknown", []string{"duplicate"}, getValueType))
+	fmt.Println(checkValueTypeMerge("canonical", []string{"duplicate", "canonical"}, getValueType))
+	fmt.Println(checkValueTypeMerge("canonical", []string{"unknown"}, getValueType))
+	fmt.Println(checkValueTypeMerge("canonical", []string{"other"}, getValueType))
+	fmt.Println(checkValueTypeMerge("canonical", []string{"broken"}, getValueType))
+	fmt.Println(checkValueTypeMerge("grandchild", []string{"child"}, getValueType))
+	fmt.Println(checkValueTypeMerge("grandchild", []string{"ancestor"}, getValueType))
+
+	//Output:
+	//<nil>
+	//missing duplicates
+	//unknown canonical valuetype id: unknown
+	//canonical valuetype is listed as duplicate
+	//unknown duplicate valuetype id: unknown
+	//valuetype other is not structurally equivalent to canonical
+	//unavailable
+	//canonical valuetype extends duplicate child
+	//canonical valuetype extends duplicate ancestor
+}
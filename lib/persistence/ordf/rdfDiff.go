@@ -17,7 +17,11 @@
 package ordf
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/knakk/rdf"
 )
@@ -70,6 +74,29 @@ func RdfHash(triple map[string]rdf.Term) string {
 	return subject + "::" + predicate + "::" + object
 }
 
+//hash of the triples of the structure; independent of the order of slices
+//entities without id (symbols) are hashed without their generated name
+func StructHash(structure interface{}) (hash string, err error) {
+	_, triples, err := StructToRdf(structure)
+	if err != nil {
+		return hash, err
+	}
+	lines := []string{}
+	for _, triple := range triples {
+		normalized := map[string]rdf.Term{}
+		for key, term := range triple {
+			if term.Type() == TermSymbol {
+				term = Symbol{}
+			}
+			normalized[key] = term
+		}
+		lines = append(lines, RdfHash(normalized))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func createRdfIndex(triples []map[string]rdf.Term) (result map[string]map[string]rdf.Term) {
 	result = map[string]map[string]rdf.Term{}
 	for _, triple := range triples {
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func (this *Persistence) getDeviceTypeForReview(id string) (deviceType model.DeviceType, err error) {
+	deviceType, err = this.GetDeepDeviceTypeById(id)
+	if err != nil {
+		return
+	}
+	if deviceType.Name == "" {
+		return deviceType, errors.New("unknown device type id: " + id)
+	}
+	return deviceType, checkReviewState(deviceType)
+}
+
+func checkReviewState(deviceType model.DeviceType) error {
+	if !deviceType.Generated || !contains(deviceType.Maintenance, model.MaintenanceRename) {
+		return errors.New("device type is not waiting for review")
+	}
+	return nil
+}
+
+//returns the generated device type with the changes of the review; the result is no longer marked as generated
+//changedValueTypes are the ids of value types renamed or with renamed fields; they may not be used by other device types
+func (this *Persistence) GetAcceptedDeviceType(id string, review model.DeviceTypeReview) (deviceType model.DeviceType, changedValueTypes []string, err error) {
+	deviceType, err = this.getDeviceTypeForReview(id)
+	if err != nil {
+		return
+	}
+	isVendor, err := this.ordf.IdIsOfClass(model.Vendor{Id: review.Vendor})
+	if err != nil {
+		return
+	}
+	if !isVendor {
+		return deviceType, nil, errors.New("unknown vendor id: " + review.Vendor)
+	}
+	isDeviceClass, err := this.ordf.IdIsOfClass(model.DeviceClass{Id: review.DeviceClass})
+	if err != nil {
+		return
+	}
+	if !isDeviceClass {
+		return deviceType, nil, errors.New("unknown device class id: " + review.DeviceClass)
+	}
+	changedValueTypes, err = applyDeviceTypeReview(&deviceType, review)
+	if err != nil {
+		return
+	}
+	err = this.checkValueTypesOnlyUsedBy(id, changedValueTypes)
+	return
+}
+
+//changes the device type by the review; returns the ids of value types changed by the review (renamed or with renamed fields)
+func applyDeviceTypeReview(deviceType *model.DeviceType, review model.DeviceTypeReview) (changedValueTypes []string, err error) {
+	used := map[string]bool{}
+	for _, service := range deviceType.Services {
+		for _, assignment := range service.Input {
+			collectValueTypeIds(assignment.Type, used)
+		}
+		for _, assignment := range service.Output {
+			collectValueTypeIds(assignment.Type, used)
+		}
+	}
+	changed := map[string]bool{}
+	for valueTypeId, name := range review.ValueTypeNames {
+		if !used[valueTypeId] {
+			return nil, errors.New("value type " + valueTypeId + " is not used by device type")
+		}
+		if name == "" {
+			return nil, errors.New("missing name for value type " + valueTypeId)
+		}
+		changed[valueTypeId] = true
+	}
+	renamedFields, err := renameDeviceTypeFields(deviceType, review.FieldNames)
+	if err != nil {
+		return nil, err
+	}
+	for valueTypeId := range renamedFields {
+		changed[valueTypeId] = true
+	}
+
+	deviceType.Name = review.Name
+	deviceType.Description = review.Description
+	deviceType.Vendor = model.Vendor{Id: review.Vendor}
+	deviceType.DeviceClass = model.DeviceClass{Id: review.DeviceClass}
+	deviceType.Generated = false
+	maintenance := []string{}
+	for _, element := range deviceType.Maintenance {
+		if element != model.MaintenanceRename {
+			maintenance = append(maintenance, element)
+		}
+	}
+	deviceType.Maintenance = maintenance
+
+	changedValueTypes = []string{}
+	for valueTypeId := range changed {
+		changedValueTypes = append(changedValueTypes, valueTypeId)
+	}
+	sort.Strings(changedValueTypes)
+	return changedValueTypes, nil
+}
+
+//value types are shared; the review may only change value types no other device type uses
+func (this *Persistence) checkValueTypesOnlyUsedBy(deviceTypeId string, valueTypeIds []string) (err error) {
+	for _, valueTypeId := range valueTypeIds {
+		usages, err := this.GetValueTypeUsages(valueTypeId)
+		if err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			if usage.Kind == model.UsageDeviceType && usage.Id != deviceTypeId {
+				return errors.New("value type " + valueTypeId + " is also used by device type " + usage.Id)
+			}
+		}
+	}
+	return nil
+}
+
+//renames type assignments of the services and fields of their value types by id; returns the ids of value types with renamed fields
+func renameDeviceTypeFields(deviceType *model.DeviceType, names map[string]string) (valueTypes map[string]bool, err error) {
+	valueTypes = map[string]bool{}
+	found := map[string]bool{}
+	for _, service := range deviceType.Services {
+		for _, assignments := range [][]model.TypeAssignment{service.Input, service.Output} {
+			for index := range assignments {
+				assignment := &assignments[index]
+				if name, ok := names[assignment.Id]; ok {
+					if name == "" {
+						return nil, errors.New("missing name for " + assignment.Id)
+					}
+					assignment.Name = name
+					found[assignment.Id] = true
+				}
+				err = renameValueTypeFields(&assignment.Type, names, found, valueTypes)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for id := range names {
+		if !found[id] {
+			return nil, errors.New("unknown field or type assignment id: " + id)
+		}
+	}
+	return
+}
+
+func renameValueTypeFields(valueType *model.ValueType, names map[string]string, found map[string]bool, valueTypes map[string]bool) (err error) {
+	fieldNames := map[string]bool{}
+	for index := range valueType.Fields {
+		field := &valueType.Fields[index]
+		if name, ok := names[field.Id]; ok {
+			if name == "" {
+				return errors.New("missing name for " + field.Id)
+			}
+			field.Name = name
+			found[field.Id] = true
+			valueTypes[valueType.Id] = true
+		}
+		if fieldNames[field.Name] {
+			return errors.New("duplicate field name '" + field.Name + "' in value type " + valueType.Id)
+		}
+		fieldNames[field.Name] = true
+		err = renameValueTypeFields(&field.Type, names, found, valueTypes)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//names of all type assignments and fields of the device type by id
+func deviceTypeFieldNames(deviceType model.DeviceType) (names map[string]string) {
+	names = map[string]string{}
+	for _, service := range deviceType.Services {
+		for _, assignments := range [][]model.TypeAssignment{service.Input, service.Output} {
+			for _, assignment := range assignments {
+				if assignment.Id != "" && assignment.Name != "" {
+					names[assignment.Id] = assignment.Name
+				}
+				collectFieldNames(assignment.Type, names)
+			}
+		}
+	}
+	return
+}
+
+func collectFieldNames(valueType model.ValueType, names map[string]string) {
+	for _, field := range valueType.Fields {
+		if field.Id != "" && field.Name != "" {
+			names[field.Id] = field.Name
+		}
+		collectFieldNames(field.Type, names)
+	}
+}
+
+//primitive value types are not collected
+func collectValueTypeIds(valueType model.ValueType, ids map[string]bool) {
+	if valueType.Id == "" || model.GetAllowedValuesBase().IsPrimitive(valueType) {
+		return
+	}
+	ids[valueType.Id] = true
+	for _, field := range valueType.Fields {
+		collectValueTypeIds(field.Type, ids)
+	}
+}
+
+func (this *Persistence) CheckDeviceTypeReject(id string) (err error) {
+	_, err = this.getDeviceTypeForReview(id)
+	return
+}
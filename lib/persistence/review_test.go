@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func reviewTestDeviceType() model.DeviceType {
+	payload := model.ValueType{Id: "payload", Name: "payload", BaseType: model.StructBaseType, Fields: []model.FieldType{
+		{Id: "f1", Name: "v1", Type: model.ValueType{Id: "float", Name: "float", BaseType: model.XsdFloat}},
+		{Id: "f2", Name: "v2", Type: model.ValueType{Id: "string", Name: "string", BaseType: model.XsdString}},
+	}}
+	return model.DeviceType{
+		Id:          "dt",
+		Name:        "generated",
+		Generated:   true,
+		Maintenance: []string{model.MaintenanceRename, "other"},
+		Services: []model.Service{{
+			Id:     "s",
+			Output: []model.TypeAssignment{{Id: "a1", Name: "value", Type: payload}},
+		}},
+	}
+}
+
+func Example_applyDeviceTypeReview() {
+	deviceType := reviewTestDeviceType()
+	fmt.Println(checkReviewState(deviceType))
+	changed, err := applyDeviceTypeReview(&deviceType, model.DeviceTypeReview{
+		Name:           "Sensor XY",
+		Vendor:         "vendor",
+		DeviceClass:    "class",
+		ValueTypeNames: map[string]string{"payload": "sensor xy payload"},
+		FieldNames:     map[string]string{"a1": "payload", "f1": "temperature"},
+	})
+	fmt.Println(changed, err)
+	assignment := deviceType.Services[0].Output[0]
+	fmt.Println(deviceType.Name, deviceType.Generated, deviceType.Maintenance, deviceType.Vendor.Id, deviceType.DeviceClass.Id)
+	fmt.Println(assignment.Name, assignment.Type.Name, assignment.Type.Fields[0].Name, assignment.Type.Fields[1].Name)
+	fmt.Println(checkReviewState(deviceType))
+
+	//renaming the assignment only changes no value type
+	deviceType = reviewTestDeviceType()
+	fmt.Println(applyDeviceTypeReview(&deviceType, model.DeviceTypeReview{Name: "Sensor XY", FieldNames: map[string]string{"a1": "payload"}}))
+
+	//Output:
+	//<nil>
+	//[payload] <nil>
+	//Sensor XY false [other] vendor class
+	//payload payload temperature v2
+	//device type is not waiting for review
+	//[] <nil>
+}
+
+func Example_renameDeviceTypeFields() {
+	deviceType := reviewTestDeviceType()
+	fmt.Println(renameDeviceTypeFields(&deviceType, map[string]string{"f2": "v1"}))
+	deviceType = reviewTestDeviceType()
+	fmt.Println(renameDeviceTypeFields(&deviceType, map[string]string{"unknown": "v3"}))
+	deviceType = reviewTestDeviceType()
+	fmt.Println(renameDeviceTypeFields(&deviceType, map[string]string{"f1": ""}))
+
+	deviceType = reviewTestDeviceType()
+	deviceType.Services = []model.Service{{Id: "s"}}
+	fmt.Println(applyDeviceTypeReview(&deviceType, model.DeviceTypeReview{ValueTypeNames: map[string]string{"payload": "sensor xy payload"}}))
+
+	//the names of a reviewed device type are applied to the stored one
+	deviceType = reviewTestDeviceType()
+	reviewed := reviewTestDeviceType()
+	reviewed.Services[0].Output[0].Type.Fields[1].Name = "unit"
+	fmt.Println(renameDeviceTypeFields(&deviceType, deviceTypeFieldNames(reviewed)))
+	fmt.Println(deviceType.Services[0].Output[0].Type.Fields[1].Name)
+
+	//Output:
+	//map[] duplicate field name 'v1' in value type payload
+	//map[] unknown field or type assignment id: unknown
+	//map[] missing name for f1
+	//[] value type payload is not used by device type
+	//map[payload:true] <nil>
+	//unit
+}
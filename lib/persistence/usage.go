@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+type usageQueueElement struct {
+	Id   string
+	Path []string
+}
+
+//lists all elements referencing the value type directly or through other value types (breadth first)
+func (this *Persistence) GetValueTypeUsages(id string) (usages []model.ValueTypeUsage, err error) {
+	usages = []model.ValueTypeUsage{}
+	visited := map[string]bool{id: true}
+	queue := []usageQueueElement{{Id: id, Path: []string{id}}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		direct := current.Id == id
+
+		extending := []model.ValueType{}
+		err = this.ordf.SearchAll(&extending, model.ValueType{Extends: current.Id})
+		if err != nil {
+			return
+		}
+		for _, valueType := range extending {
+			path := extendPath(current.Path, valueType.Id)
+			usages = append(usages, model.ValueTypeUsage{Kind: model.UsageExtendingValueType, Id: valueType.Id, Name: valueType.Name, Direct: direct, Path: path})
+			if !visited[valueType.Id] {
+				visited[valueType.Id] = true
+				queue = append(queue, usageQueueElement{Id: valueType.Id, Path: path})
+			}
+		}
+
+		fields := []model.FieldType{}
+		err = this.ordf.SearchAll(&fields, model.FieldType{Type: model.ValueType{Id: current.Id}})
+		if err != nil {
+			return
+		}
+		for _, field := range fields {
+			fieldPath := extendPath(current.Path, field.Id)
+			usages = append(usages, model.ValueTypeUsage{Kind: model.UsageField, Id: field.Id, Name: field.Name, Direct: direct, Path: fieldPath})
+			parents := []model.ValueType{}
+			err = this.ordf.SearchAll(&parents, model.ValueType{Fields: []model.FieldType{{Id: field.Id}}})
+			if err != nil {
+				return
+			}
+			for _, parent := range parents {
+				path := extendPath(fieldPath, parent.Id)
+				usages = append(usages, model.ValueTypeUsage{Kind: model.UsageValueType, Id: parent.Id, Name: parent.Name, Direct: direct, Path: path})
+				if !visited[parent.Id] {
+					visited[parent.Id] = true
+					queue = append(queue, usageQueueElement{Id: parent.Id, Path: path})
+				}
+			}
+		}
+
+		assignmentUsages, err := this.getTypeAssignmentUsages(current, direct)
+		if err != nil {
+			return usages, err
+		}
+		usages = append(usages, assignmentUsages...)
+	}
+	return
+}
+
+func (this *Persistence) getTypeAssignmentUsages(current usageQueueElement, direct bool) (usages []model.ValueTypeUsage, err error) {
+	assignments := []model.TypeAssignment{}
+	err = this.ordf.SearchAll(&assignments, model.TypeAssignment{Type: model.ValueType{Id: current.Id}})
+	if err != nil {
+		return
+	}
+	for _, assignment := range assignments {
+		assignmentPath := extendPath(current.Path, assignment.Id)
+		usages = append(usages, model.ValueTypeUsage{Kind: model.UsageTypeAssignment, Id: assignment.Id, Name: assignment.Name, Direct: direct, Path: assignmentPath})
+		services := []model.Service{}
+		err = this.ordf.SearchAll(&services, model.Service{Input: []model.TypeAssignment{{Id: assignment.Id}}})
+		if err != nil {
+			return
+		}
+		err = this.ordf.SearchAll(&services, model.Service{Output: []model.TypeAssignment{{Id: assignment.Id}}})
+		if err != nil {
+			return
+		}
+		for _, service := range services {
+			servicePath := extendPath(assignmentPath, service.Id)
+			usages = append(usages, model.ValueTypeUsage{Kind: model.UsageService, Id: service.Id, Name: service.Name, Direct: direct, Path: servicePath})
+			deviceTypes := []model.DeviceType{}
+			err = this.ordf.SearchAll(&deviceTypes, model.DeviceType{Services: []model.Service{{Id: service.Id}}})
+			if err != nil {
+				return
+			}
+			for _, deviceType := range deviceTypes {
+				usages = append(usages, model.ValueTypeUsage{Kind: model.UsageDeviceType, Id: deviceType.Id, Name: deviceType.Name, Direct: direct, Path: extendPath(servicePath, deviceType.Id)})
+			}
+		}
+	}
+	return
+}
+
+func extendPath(path []string, id string) (result []string) {
+	result = make([]string, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, id)
+}
@@ -21,6 +21,7 @@ import (
 
 	"log"
 
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 )
 
@@ -28,6 +29,13 @@ func (this *Persistence) ValueTypeQuery(valueType model.ValueType) (exists bool,
 	if valueType.Id != "" {
 		return true, valueType.Id, nil
 	}
+	if valueType.Extends != "" {
+		//inherited fields are not stored with the extending value type
+		valueType.Fields, err = this.removeInheritedFields(valueType.Extends, valueType.Fields)
+		if err != nil {
+			return exists, id, err
+		}
+	}
 	found := []model.ValueType{}
 	valueType.Id = ""
 	err = this.ordf.Search(&found, valueType, 1, 0)
@@ -41,7 +49,23 @@ func (this *Persistence) ValueTypeQuery(valueType model.ValueType) (exists bool,
 	return
 }
 
+func (this *Persistence) ValueTypeNameExists(name string) (exists bool, err error) {
+	found := []model.ValueType{}
+	err = this.ordf.Search(&found, model.ValueType{Name: name}, 1, 0)
+	return len(found) > 0, err
+}
+
 func (this *Persistence) CreateValueType(element model.ValueType) (err error) {
+	//a put of a stored value type may change what it extends
+	if element.Extends != "" && element.Id != "" {
+		err = this.valueTypeExtensionIsAcyclic(element)
+		if _, invalid := err.(valueTypeExtensionCycleError); invalid {
+			return eventsourcing.InvalidCommandError{Reason: err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+	}
 	_, err = this.ordf.Insert(element)
 	return
 }
@@ -51,12 +75,95 @@ func (this *Persistence) GetValueTypeList(limit int, offset int) (valueTypes []m
 	return
 }
 
+//returns the value type with inherited fields resolved
 func (this *Persistence) GetValueTypeById(id string) (valueType model.ValueType, err error) {
+	valueType, err = this.getValueTypeDeclaration(id)
+	if err != nil {
+		return
+	}
+	return this.ResolveValueTypeInheritance(valueType)
+}
+
+//returns the value type as stored, without inherited fields
+func (this *Persistence) getValueTypeDeclaration(id string) (valueType model.ValueType, err error) {
 	valueType.Id = id
 	err = this.ordf.SelectLevel(&valueType, -1)
 	return
 }
 
+func (this *Persistence) RenameValueType(id string, name string) (err error) {
+	old, err := this.getValueTypeDeclaration(id)
+	if err != nil {
+		return err
+	}
+	if old.Name == "" {
+		return errors.New("unknown valuetype id: " + id)
+	}
+	renamed := old
+	renamed.Name = name
+	_, err = this.ordf.Update(old, renamed)
+	return
+}
+
+func (this *Persistence) ResolveValueTypeInheritance(valueType model.ValueType) (result model.ValueType, err error) {
+	return this.resolveValueTypeInheritance(valueType, []string{})
+}
+
+func (this *Persistence) resolveValueTypeInheritance(valueType model.ValueType, extendedBy []string) (result model.ValueType, err error) {
+	result = valueType
+	result.Fields = nil
+	if valueType.Extends != "" {
+		extendedBy = append(extendedBy, valueType.Id)
+		if contains(extendedBy, valueType.Extends) {
+			return result, errors.New("cyclic value type inheritance: " + valueType.Id + " extends " + valueType.Extends)
+		}
+		parent, err := this.getValueTypeDeclaration(valueType.Extends)
+		if err != nil {
+			return result, err
+		}
+		if parent.Name == "" {
+			return result, errors.New("unknown extended value type: " + valueType.Extends)
+		}
+		parent, err = this.resolveValueTypeInheritance(parent, extendedBy)
+		if err != nil {
+			return result, err
+		}
+		result.Fields = append(result.Fields, parent.Fields...)
+	}
+	for _, field := range valueType.Fields {
+		field.Type, err = this.resolveValueTypeInheritance(field.Type, []string{})
+		if err != nil {
+			return result, err
+		}
+		result.Fields = append(result.Fields, field)
+	}
+	return
+}
+
+func (this *Persistence) removeInheritedFields(extends string, fields []model.FieldType) (result []model.FieldType, err error) {
+	parent, err := this.GetValueTypeById(extends)
+	if err != nil {
+		return result, err
+	}
+	inherited := map[string]bool{}
+	for _, field := range parent.Fields {
+		inherited[field.Name] = true
+	}
+	for _, field := range fields {
+		if !inherited[field.Name] {
+			result = append(result, field)
+		}
+	}
+	return
+}
+
+//the value type is still referenced
+type valueTypeDependencyError string
+
+func (this valueTypeDependencyError) Error() string {
+	return string(this)
+}
+
 func (this *Persistence) CheckValueTypeDelete(id string) (err error) {
 	valuetype := model.ValueType{Fields: []model.FieldType{{Type: model.ValueType{Id: id}}}}
 	valueTypes := []model.ValueType{}
@@ -65,7 +172,17 @@ func (this *Persistence) CheckValueTypeDelete(id string) (err error) {
 		return
 	}
 	if len(valueTypes) > 0 {
-		err = errors.New("dependent valueTypes found: " + valueTypes[0].Name + ", " + valueTypes[0].Id)
+		err = valueTypeDependencyError("dependent valueTypes found: " + valueTypes[0].Name + ", " + valueTypes[0].Id)
+		return
+	}
+
+	valueTypes = []model.ValueType{}
+	err = this.ordf.Search(&valueTypes, model.ValueType{Extends: id}, 1, 0)
+	if err != nil {
+		return
+	}
+	if len(valueTypes) > 0 {
+		err = valueTypeDependencyError("extending valueTypes found: " + valueTypes[0].Name + ", " + valueTypes[0].Id)
 		return
 	}
 
@@ -76,7 +193,7 @@ func (this *Persistence) CheckValueTypeDelete(id string) (err error) {
 		return
 	}
 	if len(deviceTypes) > 0 {
-		err = errors.New("dependent devicetype found: " + deviceTypes[0].Name + ", " + deviceTypes[0].Id)
+		err = valueTypeDependencyError("dependent devicetype found: " + deviceTypes[0].Name + ", " + deviceTypes[0].Id)
 		return
 	}
 
@@ -87,14 +204,22 @@ func (this *Persistence) CheckValueTypeDelete(id string) (err error) {
 		return
 	}
 	if len(deviceTypes) > 0 {
-		err = errors.New("dependent devicetype found: " + deviceTypes[0].Name + ", " + deviceTypes[0].Id)
+		err = valueTypeDependencyError("dependent devicetype found: " + deviceTypes[0].Name + ", " + deviceTypes[0].Id)
 		return
 	}
 	return nil
 }
 
 func (this *Persistence) DeleteValueType(id string) (err error) {
-	vt, err := this.GetValueTypeById(id)
+	//references may have been added since the api checked the delete
+	err = this.CheckValueTypeDelete(id)
+	if _, dependent := err.(valueTypeDependencyError); dependent {
+		return eventsourcing.InvalidCommandError{Reason: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	vt, err := this.getValueTypeDeclaration(id)
 	log.Println("DEBUG: delete valuetype", id, err, vt)
 	if err != nil {
 		return err
@@ -106,3 +231,12 @@ func (this *Persistence) DeleteValueType(id string) (err error) {
 func (this *Persistence) ValueTypeIdExists(id string) (exists bool, err error) {
 	return this.ordf.IdExists(id)
 }
+
+func contains(list []string, element string) bool {
+	for _, e := range list {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
@@ -44,23 +44,55 @@ type ConfigStruct struct {
 	ForceUser string
 	ForceAuth string
 
+	Broker       string //amqp (default), kafka or memory
+	KafkaBrokers string //comma separated list of host:port
+
 	AmqpUrl              string
 	AmqpReconnectTimeout int64
 	AmqpConsumerName     string
+	InstanceId           string //stable name of this instance for per-instance consumers (e.g. the pod name of a stateful set); defaults to the hostname
 	DeviceInstanceTopic  string
 	DeviceTypeTopic      string
 	GatewayTopic         string
 	ValueTypeTopic       string
 
+	OutboxDir              string
+	OutboxMaxAttempts      int64 //0 = unlimited
+	OutboxRetryInterval    int64 //seconds; doubled on each attempt
+	OutboxMaxRetryInterval int64 //seconds
+
+	DeadLetterDir           string
+	DeadLetterMaxAttempts   int64 //attempts to apply a consumed command before it is moved to the dead letters; 0 = unlimited
+	DeadLetterRetryInterval int64 //seconds
+
+	CommandLogFile string //applied commands are appended if set
+
+	CommandStatusTTL    int64  //seconds
+	CommandMaxWait      int64  //seconds
+	CommandStatusTopic  string //results of consumed commands are published to this topic and consumed by each instance; "" = each instance only knows the results of its own consumers
+	CommandLegacyFields string //"true" adds the fields of version 1 commands to the top level of published commands, for consumers which do not read the envelope yet
+
 	DeviceInstanceDtFieldSearchName      string
 	DeviceInstanceUrlFieldSearchName     string
 	DeviceTypeServiceFieldSearchName     string
 	DeviceTypeMaintenanceFieldSearchName string
 
+	PermissionsEngine      string //"search" (default) uses the permissionsearch service of PermissionsUrl, "embedded" evaluates auth entries of the database
 	PermissionsUrl         string
 	DefaultPermissionsUser string
+	PermissionsCacheTTL    int64  //seconds; 0 disables the cache of permission decisions
+	PermissionsTopic       string //cached decisions are invalidated on commands of this topic
+
+	EndpointPatternCacheTTL int64 //seconds; 0 disables the cache of endpoint patterns; changes of other instances are visible after this time
 
 	FlushOnStartup string
+	FlushRateLimit int64 //entities per second and kind published by the startup flush; 0 = unlimited
+
+	GatewayHeartbeatTimeout        int64  //seconds without heartbeat until a gateway is marked offline; 0 disables the check
+	GatewayConnectionCheckInstance string //InstanceId of the only instance which checks for offline gateways; defaults to this instance (single instance setups)
+
+	GatewayClaimTokenTTL       int64  //seconds
+	GatewayCredentialsRequired string //"true" rejects commit, heartbeat and sync requests of users; gateways have to use their credentials
 }
 
 type ConfigType *ConfigStruct
@@ -87,7 +119,43 @@ func LoadConfig(location string) error {
 }
 
 func HandleDefaultValues(config ConfigType) {
-
+	if config.OutboxDir == "" {
+		config.OutboxDir = "outbox"
+	}
+	if config.OutboxRetryInterval <= 0 {
+		config.OutboxRetryInterval = 1
+	}
+	if config.OutboxMaxRetryInterval <= 0 {
+		config.OutboxMaxRetryInterval = 60
+	}
+	if config.OutboxMaxRetryInterval < config.OutboxRetryInterval {
+		config.OutboxMaxRetryInterval = config.OutboxRetryInterval
+	}
+	if config.DeadLetterDir == "" {
+		config.DeadLetterDir = "deadletters"
+	}
+	if config.DeadLetterRetryInterval <= 0 {
+		config.DeadLetterRetryInterval = 1
+	}
+	if config.CommandStatusTTL <= 0 {
+		config.CommandStatusTTL = 600
+	}
+	if config.CommandMaxWait <= 0 {
+		config.CommandMaxWait = 30
+	}
+	if config.InstanceId == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Println("WARNING: unable to read hostname as instance id", err)
+		}
+		config.InstanceId = hostname
+	}
+	if config.GatewayConnectionCheckInstance == "" {
+		config.GatewayConnectionCheckInstance = config.InstanceId
+	}
+	if config.GatewayClaimTokenTTL <= 0 {
+		config.GatewayClaimTokenTTL = 3600
+	}
 }
 
 var camel = regexp.MustCompile("(^[^A-Z]*|[A-Z]*)([A-Z][^A-Z]+|$)")
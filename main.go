@@ -23,6 +23,7 @@ import (
 
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/api"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/permission"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/persistence"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
@@ -30,6 +31,7 @@ import (
 func main() {
 	defer fmt.Println("exit application")
 	configLocation := flag.String("config", "config.json", "configuration file")
+	rebuild := flag.String("rebuild", "", "command log file to replay into a empty database; exits afterwards")
 	flag.Parse()
 
 	err := util.LoadConfig(*configLocation)
@@ -39,8 +41,39 @@ func main() {
 		log.Println("prepare connection to database")
 		db := persistence.New()
 
+		if *rebuild != "" {
+			log.Println("rebuild database from command log", *rebuild)
+			lastPercent := -1
+			err = eventsourcing.RebuildFromCommandLog(db, *rebuild, func(done int, total int) {
+				percent := done * 100 / total
+				if percent != lastPercent {
+					lastPercent = percent
+					log.Printf("rebuild: %d%% (%d/%d commands)\n", percent, done, total)
+				}
+			})
+			if err != nil {
+				log.Fatal("ERROR: rebuild failed: ", err)
+			}
+			log.Println("rebuild finished")
+			return
+		}
+
+		if util.Config.PermissionsEngine == "embedded" {
+			log.Println("use embedded permission engine")
+			permission.SetEngine(permission.NewEmbedded(db))
+			if util.Config.DefaultPermissionsUser != "" {
+				count, err := db.BackfillAuth(util.Config.DefaultPermissionsUser)
+				if err != nil {
+					log.Println("ERROR: unable to backfill auth entries", err)
+				} else if count > 0 {
+					log.Println("created auth entries for resources without owner:", count)
+				}
+			}
+		}
+
 		log.Println("init eventsourcing")
 		eventsourcing.InitEventHandling(db)
+		eventsourcing.StartGatewayConnectionCheck(db)
 
 		api.Init(db)
 	}
@@ -17,6 +17,7 @@
 package tests
 
 import (
+	"strconv"
 	"time"
 
 	"testing"
@@ -242,3 +243,85 @@ func TestEndpointGenerated(t *testing.T) {
 
 	//TODO: test migration
 }
+
+func TestEndpointBatch(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := map[string]model.Endpoint{}
+	for _, endpoint := range []string{"foo/batch/1", "foo/batch/2"} {
+		newEndpoints := []model.Endpoint{}
+		err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/endpoint/generate", gen.EndpointGenMsg{
+			ProtocolHandler: "mqtt",
+			Endpoint:        endpoint,
+			Parts:           []gen.EndpointGenMsgPart{{MsgSegmentName: "payload", Msg: `{"foo": "bar"}`}},
+		}, &newEndpoints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(newEndpoints) != 1 {
+			t.Fatal(newEndpoints)
+		}
+		generated[endpoint] = newEndpoints[0]
+	}
+
+	time.Sleep(5 * time.Second)
+
+	//more endpoint strings than one query resolves; unknown strings are ignored
+	request := []string{"foo/batch/1"}
+	for i := 0; i < 150; i++ {
+		request = append(request, "unknown/"+strconv.Itoa(i))
+	}
+	request = append(request, "foo/batch/2")
+
+	endpoints := []model.Endpoint{}
+	err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/endpoint/in/batch", map[string]interface{}{"protocol_handler": "mqtt", "endpoints": request}, &endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatal(endpoints)
+	}
+	for _, endpoint := range endpoints {
+		expected, ok := generated[endpoint.Endpoint]
+		if !ok || endpoint.Device != expected.Device || endpoint.Service != expected.Service ||
+			endpoint.ServiceType != model.SensorServiceType || endpoint.Protocol == "" {
+			t.Fatal(endpoint, expected)
+		}
+	}
+
+	//endpoints stored without service type and protocol are completed from their service
+	stored := []model.Endpoint{}
+	ordf := db.GetOrdf()
+	err = ordf.SearchAll(&stored, model.Endpoint{Endpoint: "foo/batch/1", ProtocolHandler: "mqtt"})
+	if err != nil || len(stored) != 1 {
+		t.Fatal(stored, err)
+	}
+	legacy := stored[0]
+	legacy.ServiceType = ""
+	legacy.Protocol = ""
+	_, err = ordf.Update(stored[0], legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed, err := db.GetEndpointsByList([]string{"foo/batch/1"}, "mqtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 || completed[0].ServiceType != model.SensorServiceType || completed[0].Protocol != stored[0].Protocol {
+		t.Fatal(completed, stored)
+	}
+
+	//only sensor endpoints of devices with execute access are returned
+	endpoints = []model.Endpoint{}
+	err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/endpoint/in/batch", map[string]interface{}{"protocol_handler": "mqtt", "endpoints": []string{"foo/batch/1"}}, &endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 || endpoints[0].ServiceType != model.SensorServiceType {
+		t.Fatal(endpoints)
+	}
+}
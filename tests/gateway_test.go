@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+)
+
+func TestGatewayTree(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"gw2", "gw3"} {
+		err = db.SetGatway(id, "gateway "+id, "h-"+id, []string{}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.SetGatway("gw1", "gateway gw1", "h-gw1", []string{}, []string{"gw2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SetGatway("gw2", "gateway gw2", "h-gw2", []string{}, []string{"gw3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := db.GetGatewayTree("gw1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Gateways) != 1 || tree.Gateways[0].Id != "gw2" || tree.Gateways[0].Parent != "gw1" ||
+		len(tree.Gateways[0].Gateways) != 1 || tree.Gateways[0].Gateways[0].Id != "gw3" || tree.Gateways[0].Gateways[0].Parent != "gw2" {
+		t.Fatal(tree)
+	}
+
+	//the consumer rejects cycles, second parents and unknown gateways without retry
+	err = db.SetGatway("gw3", "gateway gw3", "h-gw3", []string{}, []string{"gw1"})
+	if !eventsourcing.IsInvalidCommand(err) {
+		t.Fatal("expect cycle error", err)
+	}
+	err = db.SetGatway("gw4", "gateway gw4", "h-gw4", []string{}, []string{"gw3"})
+	if !eventsourcing.IsInvalidCommand(err) {
+		t.Fatal("expect second parent error", err)
+	}
+	err = db.SetGatway("gw4", "gateway gw4", "h-gw4", []string{}, []string{"unknown"})
+	if !eventsourcing.IsInvalidCommand(err) {
+		t.Fatal("expect unknown gateway error", err)
+	}
+
+	//changes of a connected gateway reset the hash of its ancestors
+	err = db.SetGatway("gw3", "gateway gw3", "h-gw3-changed", []string{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Second)
+	for _, id := range []string{"gw2", "gw1"} {
+		gateway, err := db.GetGateway(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gateway.Hash != "" {
+			t.Fatal("expect reset hash", gateway)
+		}
+	}
+
+	//deleting a gateway releases its children and removes it from its parent
+	err = db.DeleteGateway("gw2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := db.GetGateway("gw1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parent.Gateways) != 0 {
+		t.Fatal("expect removed child", parent)
+	}
+	child, err := db.GetGateway("gw3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Name == "" || child.Parent != "" {
+		t.Fatal("expect released child", child)
+	}
+	err = db.SetGatway("gw1", "gateway gw1", "h-gw1", []string{}, []string{"gw3"})
+	if err != nil {
+		t.Fatal("expect released child to be connectable", err)
+	}
+}
+
+func TestGatewayDeleteState(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.SetGatway("gw1", "gateway 1", "h1", []string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SetGatewaySyncState(model.GatewaySyncState{Id: "gw1", Hash: "h1", Devices: []string{"d1 dh1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SetGatewayConnection(model.GatewayConnection{Id: "gw1", Online: true, LastSeen: "2018-01-01T10:00:00Z", Version: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := db.GetGatewaySyncState("gw1")
+	if err != nil || state.Hash != "h1" || len(state.Devices) != 1 {
+		t.Fatal(state, err)
+	}
+
+	err = db.DeleteGateway("gw1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = db.GetGatewaySyncState("gw1")
+	if err != nil || state.Hash != "" || len(state.Devices) != 0 {
+		t.Fatal("sync state not deleted", state, err)
+	}
+	connection, err := db.GetGatewayConnection("gw1")
+	if err != nil || connection.Online || connection.LastSeen != "" || connection.Version != "" {
+		t.Fatal("connection not deleted", connection, err)
+	}
+	online, err := db.GetOnlineGatewayConnections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, connection := range online {
+		if connection.Id == "gw1" {
+			t.Fatal("deleted gateway is online", online)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/api"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/gen"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
+	"github.com/SmartEnergyPlatform/util/http/response"
+)
+
+//PostJSON only fails on 401
+func reviewTestPost(path string, body interface{}) (status int, result response.ErrorMessage, err error) {
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(body)
+	if err != nil {
+		return
+	}
+	resp, err := Jwtuser.Post("http://localhost:"+util.Config.ServerPort+path, "application/json", b)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+	}
+	return resp.StatusCode, result, err
+}
+
+func reviewTestGenerate(t *testing.T, endpoint string, msg string) (device model.DeviceInstance, deviceType model.DeviceType) {
+	endpoints := []model.Endpoint{}
+	err := Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/endpoint/generate", gen.EndpointGenMsg{
+		ProtocolHandler: "mqtt",
+		Endpoint:        endpoint,
+		Parts:           []gen.EndpointGenMsgPart{{MsgSegmentName: "payload", Msg: msg}},
+	}, &endpoints)
+	if err != nil || len(endpoints) != 1 {
+		t.Fatal(err, endpoints)
+	}
+	time.Sleep(5 * time.Second)
+	err = Jwtuser.GetJSON("http://localhost:"+util.Config.ServerPort+"/deviceInstance/"+url.PathEscape(endpoints[0].Device), &device)
+	if err != nil || device.DeviceType == "" {
+		t.Fatal(err, device)
+	}
+	err = Jwtuser.GetJSON("http://localhost:"+util.Config.ServerPort+"/deviceType/"+url.PathEscape(device.DeviceType), &deviceType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deviceType.Generated || len(deviceType.Maintenance) != 1 || deviceType.Maintenance[0] != model.MaintenanceRename {
+		t.Fatal(deviceType)
+	}
+	return
+}
+
+func TestDeviceTypeReview(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendor := api.Insert_OK{}
+	err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/other/vendor", model.Vendor{Name: "review vendor"}, &vendor)
+	if err != nil || vendor.CreatedId == "" {
+		t.Fatal(err, vendor)
+	}
+	deviceClass := api.Insert_OK{}
+	err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/other/deviceclass", model.DeviceClass{Name: "review class"}, &deviceClass)
+	if err != nil || deviceClass.CreatedId == "" {
+		t.Fatal(err, deviceClass)
+	}
+
+	_, deviceType := reviewTestGenerate(t, "review/accept", `{"temp": 1.5, "unit": "C"}`)
+	if len(deviceType.Services) != 1 || len(deviceType.Services[0].Output) != 1 || len(deviceType.Services[0].Output[0].Type.Fields) != 2 {
+		t.Fatal(deviceType)
+	}
+	field := deviceType.Services[0].Output[0].Type.Fields[0]
+	review := model.DeviceTypeReview{
+		Name:        "reviewed",
+		Description: "reviewed",
+		Vendor:      vendor.CreatedId,
+		DeviceClass: deviceClass.CreatedId,
+		FieldNames:  map[string]string{field.Id: field.Name + "_renamed"},
+	}
+	status, result, err := reviewTestPost("/deviceType/"+url.PathEscape(deviceType.Id)+"/review/accept", review)
+	if err != nil || status != http.StatusOK {
+		t.Fatal(err, status, result)
+	}
+	time.Sleep(5 * time.Second)
+
+	accepted := model.DeviceType{}
+	err = Jwtuser.GetJSON("http://localhost:"+util.Config.ServerPort+"/deviceType/"+url.PathEscape(deviceType.Id), &accepted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Generated || len(accepted.Maintenance) != 0 || accepted.Name != review.Name || accepted.Vendor.Id != vendor.CreatedId || accepted.DeviceClass.Id != deviceClass.CreatedId {
+		t.Fatal(accepted)
+	}
+	renamed := false
+	for _, f := range accepted.Services[0].Output[0].Type.Fields {
+		renamed = renamed || (f.Id == field.Id && f.Name == field.Name+"_renamed")
+	}
+	if !renamed {
+		t.Fatal(accepted.Services[0].Output[0].Type.Fields)
+	}
+
+	//the device type is no longer generated
+	status, result, err = reviewTestPost("/deviceType/"+url.PathEscape(deviceType.Id)+"/review/accept", review)
+	if err != nil || status != http.StatusBadRequest || result.Message != "device type is not waiting for review" {
+		t.Fatal(err, status, result)
+	}
+
+	//a device instance not created by /endpoint/generate blocks the reject
+	_, rejected := reviewTestGenerate(t, "review/reject", `{"level": 3}`)
+	manual := model.DeviceInstance{Id: "iot#review-manual-instance", Name: "manual", Url: "review-manual-instance", DeviceType: rejected.Id}
+	err = db.SetDeviceInstance(manual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, result, err = reviewTestPost("/deviceType/"+url.PathEscape(rejected.Id)+"/review/reject", nil)
+	if err != nil || status != http.StatusBadRequest || result.ErrorCode != response.ERROR_DEPENDENT_DEVICE_INSTANCE || len(result.Detail) != 1 || result.Detail[0] != manual.Id {
+		t.Fatal(err, status, result)
+	}
+	stillThere := model.DeviceType{}
+	err = Jwtuser.GetJSON("http://localhost:"+util.Config.ServerPort+"/deviceType/"+url.PathEscape(rejected.Id), &stillThere)
+	if err != nil || stillThere.Id != rejected.Id {
+		t.Fatal(err, stillThere)
+	}
+}
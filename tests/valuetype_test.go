@@ -27,6 +27,11 @@ import (
 
 	"io/ioutil"
 
+	"net/url"
+
+	"reflect"
+
+	"github.com/SmartEnergyPlatform/iot-device-repository/lib/eventsourcing"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/model"
 	"github.com/SmartEnergyPlatform/iot-device-repository/lib/util"
 )
@@ -372,3 +377,176 @@ func TestValuetypeDeleteSideeffect(t *testing.T) {
 		t.Fatal(err, vt)
 	}
 }
+
+func TestValueTypeExtendsCycle(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := model.ValueType{Id: "iot#extends-a", Name: "a", Description: "a", BaseType: model.StructBaseType}
+	b := model.ValueType{Id: "iot#extends-b", Name: "b", Description: "b", BaseType: model.StructBaseType, Extends: a.Id}
+	for _, vt := range []model.ValueType{a, b} {
+		err = db.CreateValueType(vt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//the consumer rejects a put which would let a extend its own descendant
+	a.Extends = b.Id
+	err = db.CreateValueType(a)
+	if !eventsourcing.IsInvalidCommand(err) {
+		t.Fatal("expect cycle error", err)
+	}
+	resolved, err := db.GetValueTypeById(b.Id)
+	if err != nil || resolved.Extends != a.Id {
+		t.Fatal(err, resolved)
+	}
+
+	//cycles stored before the check are reported instead of resolved endlessly
+	c := model.ValueType{Id: "iot#extends-c", Name: "c", Description: "c", BaseType: model.StructBaseType, Extends: "iot#extends-d"}
+	d := model.ValueType{Id: "iot#extends-d", Name: "d", Description: "d", BaseType: model.StructBaseType, Extends: c.Id}
+	rdf := db.GetOrdf()
+	for _, vt := range []model.ValueType{c, d} {
+		_, err = rdf.Insert(vt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.GetValueTypeById(c.Id)
+	if err == nil {
+		t.Fatal("expect cycle error")
+	}
+	err = db.ValueTypeIsConsistent(model.ValueType{Name: "e", Description: "e", BaseType: model.StructBaseType, Extends: d.Id})
+	if err == nil || err.Error() != "cyclic value type inheritance: iot#extends-d extends iot#extends-c extends iot#extends-d" {
+		t.Fatal(err)
+	}
+	err = db.ValueTypeIsConsistent(model.ValueType{Name: "e", Description: "e", BaseType: model.StructBaseType, Extends: b.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValueTypeMerge(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := model.ValueType{Id: "iot#merge-string", Name: "string", Description: "string", BaseType: model.XsdString}
+	canonical := model.ValueType{Id: "iot#merge-canonical", Name: "canonical", Description: "canonical", BaseType: model.StructBaseType, Fields: []model.FieldType{{Id: "iot#merge-canonical-v", Name: "v", Type: str}}}
+	duplicate := model.ValueType{Id: "iot#merge-duplicate", Name: "duplicate", Description: "duplicate", BaseType: model.StructBaseType, Fields: []model.FieldType{{Id: "iot#merge-duplicate-v", Name: "v", Type: str}}}
+	container := model.ValueType{Id: "iot#merge-container", Name: "container", Description: "container", BaseType: model.StructBaseType, Fields: []model.FieldType{{Id: "iot#merge-container-d", Name: "d", Type: model.ValueType{Id: duplicate.Id}}}}
+	child := model.ValueType{Id: "iot#merge-child", Name: "child", Description: "child", BaseType: model.StructBaseType, Extends: duplicate.Id}
+	grandchild := model.ValueType{Id: "iot#merge-grandchild", Name: "grandchild", Description: "grandchild", BaseType: model.StructBaseType, Extends: child.Id}
+	for _, vt := range []model.ValueType{str, canonical, duplicate, container, child, grandchild} {
+		err = db.CreateValueType(vt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := db.FindEquivalentValueTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, cluster := range clusters {
+		for _, vt := range cluster.ValueTypes {
+			found[vt.Id] = len(cluster.ValueTypes) == 4
+		}
+	}
+	if !found[canonical.Id] || !found[duplicate.Id] || !found[child.Id] || !found[grandchild.Id] || found[container.Id] {
+		t.Fatal(clusters)
+	}
+
+	//the grandchild extends the duplicate through its parent
+	err = db.CheckValueTypeMerge(grandchild.Id, []string{duplicate.Id})
+	if err == nil || err.Error() != "canonical valuetype extends duplicate "+duplicate.Id {
+		t.Fatal(err)
+	}
+	err = db.CheckValueTypeMerge(canonical.Id, []string{container.Id})
+	if err == nil {
+		t.Fatal("expect structure error")
+	}
+
+	err = Jwtuser.PostJSON("http://localhost:"+util.Config.ServerPort+"/admin/valueTypes/merge", model.ValueTypeMerge{Canonical: canonical.Id, Duplicates: []string{duplicate.Id}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Second)
+
+	merged, err := db.GetValueTypeById(container.Id)
+	if err != nil || len(merged.Fields) != 1 || merged.Fields[0].Type.Id != canonical.Id {
+		t.Fatal(err, merged)
+	}
+	merged, err = db.GetValueTypeById(child.Id)
+	if err != nil || merged.Extends != canonical.Id || len(merged.Fields) != 1 || merged.Fields[0].Name != "v" {
+		t.Fatal(err, merged)
+	}
+	removed, err := db.GetValueTypeById(duplicate.Id)
+	if err != nil || removed.Name != "" {
+		t.Fatal("duplicate not deleted", err, removed)
+	}
+
+	//the consumer rejects deletions of referenced value types
+	err = db.DeleteValueType(canonical.Id)
+	if !eventsourcing.IsInvalidCommand(err) {
+		t.Fatal("expect dependency error", err)
+	}
+}
+
+func TestValueTypeUsages(t *testing.T) {
+	purge, db, err := InitTestContainer()
+	defer purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used := model.ValueType{Id: "iot#usage-used", Name: "used", Description: "used", BaseType: model.XsdString}
+	container := model.ValueType{Id: "iot#usage-container", Name: "container", Description: "container", BaseType: model.StructBaseType, Fields: []model.FieldType{{Id: "iot#usage-container-u", Name: "u", Type: model.ValueType{Id: used.Id}}}}
+	child := model.ValueType{Id: "iot#usage-child", Name: "child", Description: "child", BaseType: model.StructBaseType, Extends: container.Id}
+	for _, vt := range []model.ValueType{used, container, child} {
+		err = db.CreateValueType(vt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.SetDeviceType(model.DeviceType{Id: "iot#usage-devicetype", Name: "devicetype", Services: []model.Service{{
+		Id:     "iot#usage-service",
+		Name:   "service",
+		Output: []model.TypeAssignment{{Id: "iot#usage-assignment", Name: "assignment", Type: model.ValueType{Id: child.Id}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usages := []model.ValueTypeUsage{}
+	err = Jwtuser.GetJSON("http://localhost:"+util.Config.ServerPort+"/valueType/"+url.PathEscape(used.Id)+"/usages", &usages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []model.ValueTypeUsage{
+		{Kind: model.UsageField, Id: "iot#usage-container-u", Name: "u", Direct: true, Path: []string{used.Id, "iot#usage-container-u"}},
+		{Kind: model.UsageValueType, Id: container.Id, Name: container.Name, Direct: true, Path: []string{used.Id, "iot#usage-container-u", container.Id}},
+		{Kind: model.UsageExtendingValueType, Id: child.Id, Name: child.Name, Direct: false, Path: []string{used.Id, "iot#usage-container-u", container.Id, child.Id}},
+		{Kind: model.UsageTypeAssignment, Id: "iot#usage-assignment", Name: "assignment", Direct: false, Path: []string{used.Id, "iot#usage-container-u", container.Id, child.Id, "iot#usage-assignment"}},
+		{Kind: model.UsageService, Id: "iot#usage-service", Name: "service", Direct: false, Path: []string{used.Id, "iot#usage-container-u", container.Id, child.Id, "iot#usage-assignment", "iot#usage-service"}},
+		{Kind: model.UsageDeviceType, Id: "iot#usage-devicetype", Name: "devicetype", Direct: false, Path: []string{used.Id, "iot#usage-container-u", container.Id, child.Id, "iot#usage-assignment", "iot#usage-service", "iot#usage-devicetype"}},
+	}
+	if !reflect.DeepEqual(usages, expected) {
+		t.Fatal(usages)
+	}
+
+	resp, err := Jwtuser.Get("http://localhost:" + util.Config.ServerPort + "/valueType/" + url.PathEscape("iot#usage-unknown") + "/usages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatal(resp.StatusCode)
+	}
+}